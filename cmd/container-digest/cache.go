@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fdrake/container-digest/internal/cache"
+	"github.com/spf13/cobra"
+)
+
+// openCache opens the on-disk cache in the user's cache directory
+func openCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir, cacheTTL), nil
+}
+
+// newCacheCmd creates the command that manages the on-disk manifest cache
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect, prune or clear the on-disk manifest cache",
+		Long:  `cache manages the manifests, image configs and tag entries kept between runs. Entries are considered expired once they are older than --cache-ttl.`,
+	}
+
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "info",
+		Short: "Show where the cache is and what it holds",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheInfo,
+	})
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "prune",
+		Short: "Remove expired tag entries and blobs unused for longer than --cache-ttl",
+		Args:  cobra.NoArgs,
+		RunE:  runCachePrune,
+	})
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove everything in the cache",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheClear,
+	})
+
+	return cacheCmd
+}
+
+func runCacheInfo(cmd *cobra.Command, args []string) error {
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		return fmt.Errorf("error reading cache: %w", err)
+	}
+
+	fmt.Printf("Cache directory: %s\n", c.Dir())
+	fmt.Printf("Tags: %d (%d older than %s)\n", info.Tags, info.ExpiredTags, cacheTTL)
+	fmt.Printf("Manifests and configs: %d\n", info.Blobs)
+	fmt.Printf("Size: %s\n", formatBytes(info.Size))
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	removed, err := c.Prune()
+	if err != nil {
+		return fmt.Errorf("error pruning cache: %w", err)
+	}
+
+	fmt.Printf("Removed %d cache entries older than %s\n", removed, cacheTTL)
+	return nil
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Clear(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Cleared %s\n", c.Dir())
+	return nil
+}
+
+// formatBytes formats a size in bytes for display (e.g., 1.5 MiB)
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		value /= unit
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%.1f TiB", value/unit)
+}
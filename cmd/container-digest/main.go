@@ -2,44 +2,239 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/fdrake/container-digest/internal/cache"
 	"github.com/fdrake/container-digest/internal/config"
+	"github.com/fdrake/container-digest/internal/lock"
 	"github.com/fdrake/container-digest/internal/models"
 	"github.com/fdrake/container-digest/internal/registry"
 	"github.com/spf13/cobra"
 )
 
 var (
-	containersFile string
-	outputFile     string
-	outputFormat   string
+	containersFile      string
+	outputFile          string
+	outputFormat        string
+	concurrency         int
+	registryConcurrency int
+	lockFile            string
+	verbose             bool
+	retryPolicy         = registry.DefaultRetryPolicy()
+	quotaExceeded       string
+	strict              bool
+	includeIndex        bool
+	indexKey            string
+	timeout             time.Duration
+	requestTimeout      time.Duration
+	noCache             bool
+	cacheTTL            time.Duration
+	offline             bool
+	referrers           bool
+	metadata            bool
+	sizes               bool
+	mediaTypes          string
 )
 
+// registryOptions validates the flags shared by all commands and builds the registry client options from them
+func registryOptions() (registry.Options, error) {
+	opts := registry.DefaultOptions()
+
+	if concurrency < 1 || registryConcurrency < 1 {
+		return opts, fmt.Errorf("concurrency limits must be at least 1")
+	}
+	if retryPolicy.MaxAttempts < 1 {
+		return opts, fmt.Errorf("retry attempts must be at least 1")
+	}
+	if retryPolicy.Jitter < 0 || retryPolicy.Jitter > 1 {
+		return opts, fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	if timeout < 0 || requestTimeout < 0 || cacheTTL < 0 {
+		return opts, fmt.Errorf("timeouts and the cache TTL must not be negative")
+	}
+	switch quotaExceeded {
+	case registry.QuotaFail, registry.QuotaHeadOnly, registry.QuotaIgnore:
+	default:
+		return opts, fmt.Errorf("unsupported quota policy: %s (supported policies: fail, head-only, ignore)", quotaExceeded)
+	}
+	if !slices.Contains(models.MediaTypePolicies, mediaTypes) {
+		return opts, fmt.Errorf("unsupported media type policy: %s (supported policies: %s)", mediaTypes, strings.Join(models.MediaTypePolicies, ", "))
+	}
+
+	opts.Concurrency = concurrency
+	opts.RegistryConcurrency = registryConcurrency
+	opts.Retry = retryPolicy
+	opts.RequestTimeout = requestTimeout
+	opts.QuotaExceeded = quotaExceeded
+	opts.Strict = strict
+	opts.MediaTypes = mediaTypes
+
+	// Verbose messages go to stderr so they never mix with output on stdout
+	if verbose {
+		opts.Log = os.Stderr
+	}
+
+	// Runs work without the cache if there is nowhere to keep it
+	if !noCache {
+		c, err := openCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not using the cache: %v\n", err)
+		} else {
+			opts.Cache = c
+		}
+	}
+
+	return opts, nil
+}
+
+// commandContext returns the command's context, which is canceled on SIGINT or SIGTERM,
+// limited by the --timeout flag if it is set
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
 func runDigest(cmd *cobra.Command, args []string) error {
+	opts, err := registryOptions()
+	if err != nil {
+		return err
+	}
+
+	// The index key shares the level of architecture keys, which always contain a slash
+	if includeIndex && (indexKey == "" || strings.Contains(indexKey, "/")) {
+		return fmt.Errorf("invalid index key %q: must be non-empty and must not contain \"/\"", indexKey)
+	}
+	if includeIndex && (models.IsTagKey(indexKey) || indexKey == models.MetadataKey) {
+		return fmt.Errorf("invalid index key %q: reserved for resolved tags and metadata", indexKey)
+	}
+
+	// Referrers are only written in the rich output, and are always asked of the registry
+	if referrers && outputFormat != "rich" {
+		return fmt.Errorf("--referrers needs --output-format rich")
+	}
+	if referrers && offline {
+		return fmt.Errorf("--referrers can't be used with --offline, referrers are not cached")
+	}
+	opts.Referrers = referrers
+
+	// Metadata doesn't fit in the plain JSON output, where every tag maps platforms to references
+	if metadata && outputFormat != "rich" && outputFormat != "nix" {
+		return fmt.Errorf("--metadata needs --output-format rich or nix")
+	}
+	opts.Metadata = metadata
+
+	// Sizes are only written in the rich output, which is also where the previous sizes are read from
+	if sizes && outputFormat != "rich" {
+		return fmt.Errorf("--sizes needs --output-format rich")
+	}
+	opts.Sizes = sizes
+
 	// Load containers configuration
 	containersConfig, err := config.LoadContainersConfig(containersFile)
 	if err != nil {
 		return fmt.Errorf("error loading containers config: %w", err)
 	}
 
+	// Load the lock from the previous run, if enabled
+	if lockFile != "" {
+		opts.Lock, err = lock.Load(lockFile)
+		if err != nil {
+			return fmt.Errorf("error loading lock file: %w", err)
+		}
+	}
+
+	// Offline, the previous output also answers for entries the lock doesn't have
+	if offline {
+		opts.Offline = true
+		if outputFile != "" {
+			previous, err := loadPreviousOutput(outputFile, outputFormat, indexKey)
+			if err != nil {
+				return fmt.Errorf("error loading previous output: %w", err)
+			}
+			if opts.Lock == nil {
+				opts.Lock = previous
+			} else {
+				for key, entry := range previous.Entries {
+					if _, exists := opts.Lock.Entries[key]; !exists {
+						opts.Lock.Entries[key] = entry
+					}
+				}
+			}
+		}
+	}
+
 	// Create registry client
-	client, err := registry.NewClient(containersConfig)
+	client, err := registry.NewClient(containersConfig, opts)
 	if err != nil {
 		return fmt.Errorf("error creating registry client: %w", err)
 	}
+	defer client.Close()
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	// Make sure the Docker Hub entries fit in the remaining pull quota
+	quota, err := client.PreflightQuota(ctx, containersConfig)
+	if errors.Is(err, registry.ErrQuotaExceeded) {
+		return fmt.Errorf("error checking docker hub quota: %w", err)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check Docker Hub quota: %v\n", err)
+	} else if quota.Reported {
+		fmt.Fprintf(os.Stderr, "Docker Hub quota: %s\n", quota)
+	}
 
 	// Get digests for all containers
-	results, err := client.GetDigests(containersConfig)
-	if err != nil {
+	tagResults, err := client.ResolveTags(ctx, containersConfig)
+	var unresolved *registry.UnresolvedError
+	if errors.As(err, &unresolved) && errors.Is(unresolved.Err, registry.ErrOffline) {
+		return fmt.Errorf("no cached answer for %d of %d entries in offline mode; nothing was written\nUnresolved entries:\n  %s",
+			len(unresolved.Unresolved), unresolved.Total, strings.Join(unresolved.Unresolved, "\n  "))
+	} else if errors.As(err, &unresolved) {
+		return fmt.Errorf("%s before every container was resolved; nothing was written\nUnresolved entries:\n  %s",
+			stopReason(unresolved.Err), strings.Join(unresolved.Unresolved, "\n  "))
+	} else if err != nil {
 		return fmt.Errorf("error fetching container digests: %w", err)
 	}
+	var resultsIndexKey string
+	if includeIndex {
+		resultsIndexKey = indexKey
+	}
+	results := tagResults.Nested(resultsIndexKey)
+
+	if verbose {
+		stats := client.Stats()
+		fmt.Fprintf(os.Stderr, "Registry requests: %d manifest GETs, %d manifest HEADs, %d retries, %d served from cache\n",
+			stats.ManifestGets, stats.ManifestHeads, stats.Retries, stats.CacheHits)
+		if quota := client.LastQuota(); quota.Reported {
+			fmt.Fprintf(os.Stderr, "Docker Hub quota after run: %s\n", quota)
+		}
+	}
+
+	// Record this run in the lock and report how many downloads it saved.
+	// An offline run has nothing new to record.
+	if lockFile != "" && !offline {
+		if err := lock.Save(lockFile, models.NewLock(tagResults)); err != nil {
+			return fmt.Errorf("error saving lock file: %w", err)
+		}
+
+		stats := client.Stats()
+		fmt.Fprintf(os.Stderr, "Manifest GETs avoided: %d of %d tags unchanged since last run\n",
+			stats.GetsAvoided, len(tagResults))
+	}
 
 	// Generate output based on format
 	var outputData []byte
@@ -71,14 +266,32 @@ func runDigest(cmd *cobra.Command, args []string) error {
 		}
 
 		// Convert results to Nix format
-		nixOutput, err := formatAsNix(transformedResults)
+		nixOutput, err := formatAsNix(transformedResults, tagResults.Metadata())
 		if err != nil {
 			return fmt.Errorf("error encoding results to Nix format: %w", err)
 		}
 		outputData = []byte(nixOutput)
 		formatName = "Nix"
+	case "rich":
+		// Sizes are compared with the output being replaced, when it recorded them
+		richResults := tagResults.Rich()
+		if sizes && outputFile != "" {
+			previous, err := lock.LoadRichOutput(outputFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: not comparing sizes with the previous output: %v\n", err)
+			} else {
+				richResults.CompareSizes(previous)
+			}
+		}
+
+		// The rich output is made of structs and sorted maps, so it is encoded as is
+		outputData, err = json.MarshalIndent(richResults, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding results to rich JSON: %w", err)
+		}
+		formatName = "Rich JSON"
 	default:
-		return fmt.Errorf("unsupported output format: %s (supported formats: json, nix)", outputFormat)
+		return fmt.Errorf("unsupported output format: %s (supported formats: json, nix, rich)", outputFormat)
 	}
 
 	// Output data
@@ -103,8 +316,24 @@ func runDigest(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// formatAsNix converts the digest results to Nix format with alphabetically sorted keys
-func formatAsNix(results models.NestedDigestResults) (string, error) {
+// loadPreviousOutput reads the digests of the output file of a previous run in the given format, to stand in for a lock
+func loadPreviousOutput(path, format, indexKey string) (*models.Lock, error) {
+	switch format {
+	case "nix":
+		return lock.LoadNixOutput(path, indexKey)
+	case "rich":
+		results, err := lock.LoadRichOutput(path)
+		if err != nil {
+			return nil, err
+		}
+		return lock.RichOutputLock(results), nil
+	}
+	return lock.LoadOutput(path, indexKey)
+}
+
+// formatAsNix converts the digest results to Nix format with alphabetically sorted keys.
+// The metadata of a tag's platforms, if any, is added to the tag under models.MetadataKey.
+func formatAsNix(results models.NestedDigestResults, metadata models.MetadataResults) (string, error) {
 	var nixOutput string
 	nixOutput = "{...}: {\n"
 
@@ -132,11 +361,20 @@ func formatAsNix(results models.NestedDigestResults) (string, error) {
 				archs := tags[tag]
 				nixOutput += fmt.Sprintf("      \"%s\" = {\n", escapeNixString(tag))
 
-				// Get sorted architecture keys
+				// Get sorted architecture keys, along with the metadata key if the tag has metadata
 				archKeys := getSortedKeys(archs)
+				tagMetadata := metadata[registry][repo][tag]
+				if len(tagMetadata) > 0 {
+					archKeys = append(archKeys, models.MetadataKey)
+					sort.Strings(archKeys)
+				}
 
 				// Iterate through architectures in sorted order
 				for _, arch := range archKeys {
+					if arch == models.MetadataKey {
+						nixOutput += formatNixMetadata(tagMetadata)
+						continue
+					}
 					fullImageRef := archs[arch]
 					nixOutput += fmt.Sprintf("        \"%s\" = \"%s\";\n",
 						escapeNixString(arch), escapeNixString(fullImageRef))
@@ -155,6 +393,27 @@ func formatAsNix(results models.NestedDigestResults) (string, error) {
 	return nixOutput, nil
 }
 
+// formatNixMetadata formats the metadata of a tag's platforms as a Nix attribute set keyed by platform
+func formatNixMetadata(metadata map[string]models.ImageMetadata) string {
+	nixOutput := fmt.Sprintf("        \"%s\" = {\n", models.MetadataKey)
+	for _, arch := range getSortedKeys(metadata) {
+		platform := metadata[arch]
+		nixOutput += fmt.Sprintf("          \"%s\" = {\n", escapeNixString(arch))
+		nixOutput += fmt.Sprintf("            \"configDigest\" = \"%s\";\n", escapeNixString(platform.ConfigDigest))
+		if platform.Created != nil {
+			nixOutput += fmt.Sprintf("            \"created\" = \"%s\";\n", platform.Created.UTC().Format(time.RFC3339))
+		}
+		nixOutput += "            \"labels\" = {\n"
+		for _, label := range getSortedKeys(platform.Labels) {
+			nixOutput += fmt.Sprintf("              \"%s\" = \"%s\";\n", escapeNixString(label), escapeNixString(platform.Labels[label]))
+		}
+		nixOutput += "            };\n"
+		nixOutput += "          };\n"
+	}
+	nixOutput += "        };\n"
+	return nixOutput
+}
+
 // escapeNixString escapes special characters in strings for Nix format
 func escapeNixString(s string) string {
 	// Replace any special characters as needed
@@ -164,7 +423,16 @@ func escapeNixString(s string) string {
 	return s
 }
 
-// transformResultsWithFullRefs transforms the nested digest results to include full image references
+// stopReason describes why a run stopped early
+func stopReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("Timed out after %s", timeout)
+	}
+	return "Interrupted"
+}
+
+// transformResultsWithFullRefs transforms the nested digest results to include full image references.
+// References always use the upstream registry from the config, even when a mirror was queried.
 func transformResultsWithFullRefs(results models.NestedDigestResults) (models.NestedDigestResults, error) {
 	transformedResults := models.NestedDigestResults{}
 
@@ -182,6 +450,12 @@ func transformResultsWithFullRefs(results models.NestedDigestResults) (models.Ne
 
 				// Iterate through architectures
 				for arch, digest := range archs {
+					// Resolved tags are not digests and are passed through as is
+					if models.IsTagKey(arch) {
+						transformedResults[registry][repo][tag][arch] = digest
+						continue
+					}
+
 					// Format the full image reference with digest
 					fullImageRef := fmt.Sprintf("%s/%s@%s", registry, repo, digest)
 					transformedResults[registry][repo][tag][arch] = fullImageRef
@@ -325,12 +599,47 @@ func main() {
 		RunE:  runDigest,
 	}
 
-	// Define command-line flags
-	rootCmd.Flags().StringVar(&containersFile, "containers", "containers.toml", "Path to containers TOML file")
+	// Define command-line flags shared by all commands
+	rootCmd.PersistentFlags().StringVar(&containersFile, "containers", "containers.toml", "Path to containers TOML file")
+	rootCmd.PersistentFlags().IntVar(&retryPolicy.MaxAttempts, "retry-attempts", retryPolicy.MaxAttempts, "Maximum attempts per registry operation, including the first")
+	rootCmd.PersistentFlags().DurationVar(&retryPolicy.BaseDelay, "retry-delay", retryPolicy.BaseDelay, "Delay before the first retry, doubled for each following retry")
+	rootCmd.PersistentFlags().DurationVar(&retryPolicy.MaxDelay, "retry-max-delay", retryPolicy.MaxDelay, "Maximum delay between retries")
+	rootCmd.PersistentFlags().Float64Var(&retryPolicy.Jitter, "retry-jitter", retryPolicy.Jitter, "Fraction of each retry delay that is randomized (0 to 1)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Print progress details such as retries to stderr")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Time limit for the whole run (0 for none)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Don't read or write the on-disk manifest cache")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", cache.DefaultTTL, "How long a cached tag is trusted without asking the registry")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", registry.DefaultRequestTimeout, "Time limit for each registry request before it is retried (0 for none)")
+
+	// Define command-line flags for resolving digests
 	rootCmd.Flags().StringVar(&outputFile, "output", "", "Path to output file (if not specified, output to stdout)")
-	rootCmd.Flags().StringVar(&outputFormat, "output-format", "json", "Output format (json or nix)")
-
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", "json", "Output format (json, nix or rich)")
+	rootCmd.Flags().BoolVar(&includeIndex, "include-index", false, "Also output the digest of each tag's multi-arch index")
+	rootCmd.Flags().StringVar(&indexKey, "index-key", models.DefaultIndexKey, "Key the index digest is written under when --include-index is set")
+	rootCmd.Flags().StringVar(&lockFile, "lock", "", "Path to lock file recording digests between runs (if not specified, no lock is used)")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", registry.DefaultConcurrency, "Maximum number of registry lookups in flight at once")
+	rootCmd.Flags().IntVar(&registryConcurrency, "registry-concurrency", registry.DefaultRegistryConcurrency, "Maximum number of lookups in flight against a single registry")
+	rootCmd.Flags().BoolVar(&strict, "strict", true, "Fail when a requested architecture is not in the image instead of using the index digest")
+	rootCmd.Flags().StringVar(&mediaTypes, "media-types", models.DefaultMediaTypes, "Manifest media types images may be pinned to, unless a container sets its own (oci, docker-v2 or any)")
+	rootCmd.Flags().BoolVar(&offline, "offline", false, "Resolve only from the cache, the lock and the previous output at --output, in its --output-format, without contacting any registry")
+	rootCmd.Flags().BoolVar(&referrers, "referrers", false, "Look up the signatures, SBOMs and attestations attached to each digest (needs --output-format rich)")
+	rootCmd.Flags().BoolVar(&metadata, "metadata", false, "Read the created time, config digest and OCI labels of each platform (needs --output-format rich or nix)")
+	rootCmd.Flags().BoolVar(&sizes, "sizes", false, "Sum the compressed layer sizes of each platform and compare them with the previous output (needs --output-format rich)")
+	rootCmd.Flags().StringVar(&quotaExceeded, "quota-exceeded", registry.QuotaFail, "What to do when Docker Hub entries exceed the remaining pull quota (fail, head-only or ignore)")
+
+	rootCmd.AddCommand(newQuotaCmd())
+	rootCmd.AddCommand(newCacheCmd())
+
+	// Cancel in-flight requests on Ctrl-C, then let a second Ctrl-C exit immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
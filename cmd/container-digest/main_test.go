@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fdrake/container-digest/internal/models"
 )
@@ -98,7 +101,7 @@ func TestNixKeysOrdering(t *testing.T) {
 	}
 
 	// Convert to Nix format
-	nixOutput, err := formatAsNix(testData)
+	nixOutput, err := formatAsNix(testData, nil)
 	if err != nil {
 		t.Fatalf("Failed to format as Nix: %v", err)
 	}
@@ -132,6 +135,97 @@ func TestNixKeysOrdering(t *testing.T) {
 	}
 }
 
+// TestNixMetadata tests that a tag's metadata is added next to its platforms
+func TestNixMetadata(t *testing.T) {
+	testData := models.NestedDigestResults{
+		"docker.io": models.RepositoryMap{
+			"library/busybox": models.TagMap{
+				"1.36": models.ArchMap{
+					"linux/amd64": "docker.io/library/busybox@sha256:aaaa",
+				},
+			},
+		},
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	metadata := models.MetadataResults{
+		"docker.io": {
+			"library/busybox": {
+				"1.36": {
+					"linux/amd64": models.ImageMetadata{
+						Created:      &created,
+						ConfigDigest: "sha256:cccc",
+						Labels:       map[string]string{"org.opencontainers.image.version": "1.36"},
+					},
+				},
+			},
+		},
+	}
+
+	nixOutput, err := formatAsNix(testData, metadata)
+	if err != nil {
+		t.Fatalf("Failed to format as Nix: %v", err)
+	}
+
+	for _, expected := range []string{
+		`"_metadata" = {`,
+		`"configDigest" = "sha256:cccc";`,
+		`"created" = "2024-05-01T12:00:00Z";`,
+		`"org.opencontainers.image.version" = "1.36";`,
+	} {
+		if !strings.Contains(nixOutput, expected) {
+			t.Errorf("Expected the output to contain %s, got:\n%s", expected, nixOutput)
+		}
+	}
+	if strings.Index(nixOutput, `"_metadata"`) > strings.Index(nixOutput, `"linux/amd64" = "docker.io`) {
+		t.Errorf("Expected _metadata to be sorted before the platforms, got:\n%s", nixOutput)
+	}
+}
+
+// TestLoadPreviousNixOutput tests that a Nix output can be read back to answer offline runs
+func TestLoadPreviousNixOutput(t *testing.T) {
+	testData := models.NestedDigestResults{
+		"docker.io": models.RepositoryMap{
+			"library/busybox": models.TagMap{
+				"latest": models.ArchMap{
+					models.DefaultIndexKey: "docker.io/library/busybox@sha256:aaaa",
+					models.VersionKey:      "1.37.0",
+					"linux/amd64":          "docker.io/library/busybox@sha256:bbbb",
+				},
+			},
+		},
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	metadata := models.MetadataResults{
+		"docker.io": {"library/busybox": {"latest": {
+			"linux/amd64": models.ImageMetadata{Created: &created, ConfigDigest: "sha256:cccc", Labels: map[string]string{"title": `say "$hi"`}},
+		}}},
+	}
+
+	nixOutput, err := formatAsNix(testData, metadata)
+	if err != nil {
+		t.Fatalf("Failed to format as Nix: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "containers.nix")
+	if err := os.WriteFile(path, []byte(nixOutput), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+
+	previous, err := loadPreviousOutput(path, "nix", models.DefaultIndexKey)
+	if err != nil {
+		t.Fatalf("loadPreviousOutput returned an error: %v", err)
+	}
+	expected := map[string]models.LockEntry{
+		"docker.io/library/busybox:latest": {
+			Digest:     "sha256:aaaa",
+			VersionTag: "1.37.0",
+			Platforms:  models.ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+	}
+	if !reflect.DeepEqual(previous.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, previous.Entries)
+	}
+}
+
 // TestGetSortedKeys tests the getSortedKeys helper function
 func TestGetSortedKeys(t *testing.T) {
 	// Test with a map[string]interface{}
@@ -162,3 +256,17 @@ func TestGetSortedKeys(t *testing.T) {
 		t.Errorf("Expected nil for non-map input, got %v", nonMapKeys)
 	}
 }
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+
+	for size, expected := range tests {
+		if got := formatBytes(size); got != expected {
+			t.Errorf("Expected formatBytes(%d) to be %q, got %q", size, expected, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/fdrake/container-digest/internal/config"
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+// newQuotaCmd creates the command that reports the remaining Docker Hub pull quota
+func newQuotaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "quota",
+		Short: "Show the remaining Docker Hub pull quota",
+		Long:  `quota checks the Docker Hub pull rate limit with a HEAD request, which does not count as a pull, and compares it to the number of docker.io entries in the containers file.`,
+		Args:  cobra.NoArgs,
+		RunE:  runQuota,
+	}
+}
+
+func runQuota(cmd *cobra.Command, args []string) error {
+	opts, err := registryOptions()
+	if err != nil {
+		return err
+	}
+
+	// The containers file is optional here, the quota can be checked without it
+	containersConfig, err := config.LoadContainersConfig(containersFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		containersConfig = &models.ContainersConfig{}
+	} else if err != nil {
+		return fmt.Errorf("error loading containers config: %w", err)
+	}
+
+	client, err := registry.NewClient(containersConfig, opts)
+	if err != nil {
+		return fmt.Errorf("error creating registry client: %w", err)
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	quota, err := client.CheckQuota(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking docker hub quota: %w", err)
+	}
+
+	if !quota.Reported {
+		fmt.Println("Docker Hub did not report a pull limit for these credentials")
+		return nil
+	}
+
+	entries := containersConfig.CountRegistry(registry.DockerHub)
+	pulls := client.HubPulls(containersConfig)
+	fmt.Printf("Pull limit: %d", quota.Limit)
+	if quota.Window > 0 {
+		fmt.Printf(" per %s", quota.Window)
+	}
+	fmt.Printf("\nRemaining pulls: %d\n", quota.Remaining)
+	fmt.Printf("docker.io entries in %s: %d, needing up to %d pulls\n", containersFile, entries, pulls)
+	if pulls > quota.Remaining {
+		fmt.Println("Warning: a full run needs more pulls than remain")
+	}
+
+	return nil
+}
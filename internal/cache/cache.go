@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is how long a tag is trusted to point to the same digest without asking the registry
+const DefaultTTL = time.Hour
+
+// Directories inside the cache
+const (
+	blobsDir = "blobs" // Manifests and configs, stored by digest (e.g., blobs/sha256/<hex>)
+	tagsDir  = "tags"  // Tag entries, stored by the SHA-256 of their reference
+	listsDir = "lists" // Tag lists, stored by the SHA-256 of their repository
+)
+
+// mediaTypeSuffix is appended to a blob's path to store its media type next to it
+const mediaTypeSuffix = ".type"
+
+// Cache stores manifests and configs by digest, and which digest each tag pointed to.
+// Blobs never expire since they can't change; tag entries are only fresh for the TTL.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// TagEntry records the digest a tag pointed to when it was last fetched
+type TagEntry struct {
+	Reference string    `json:"reference"`  // Full reference of the tag (e.g., docker.io/library/busybox:latest)
+	Digest    string    `json:"digest"`     // Digest of the manifest the tag pointed to
+	MediaType string    `json:"media_type"` // Media type of that manifest
+	Fetched   time.Time `json:"fetched"`    // When the tag was resolved by the registry
+}
+
+// TagListEntry records the tags a repository had when they were last listed
+type TagListEntry struct {
+	Repository string    `json:"repository"` // Full name of the repository (e.g., docker.io/library/postgres)
+	Tags       []string  `json:"tags"`       // Tags in the repository
+	Fetched    time.Time `json:"fetched"`    // When the tags were listed by the registry
+}
+
+// DefaultDir returns the cache directory in the user's cache directory
+// (e.g., $XDG_CACHE_HOME/container-digest or ~/.cache/container-digest)
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "container-digest"), nil
+}
+
+// New creates a cache in a directory, which is created when the first entry is stored
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// Dir returns the directory the cache is stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Fresh reports whether an entry fetched at the given time is recent enough to be used without asking the registry
+func (c *Cache) Fresh(fetched time.Time) bool {
+	return c.now().Sub(fetched) < c.ttl
+}
+
+// GetBlob returns a blob and its media type. Blobs that fail digest verification
+// are treated as missing, so a corrupted cache falls back to the registry.
+func (c *Cache) GetBlob(digest string) (string, []byte, bool) {
+	path, err := c.blobPath(digest)
+	if err != nil {
+		return "", nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || verify(digest, data) != nil {
+		return "", nil, false
+	}
+	mediaType, err := os.ReadFile(path + mediaTypeSuffix)
+	if err != nil {
+		return "", nil, false
+	}
+
+	// Record the use so pruning keeps blobs that are still needed
+	now := c.now()
+	_ = os.Chtimes(path, now, now)
+
+	return string(mediaType), data, true
+}
+
+// PutBlob stores a blob after checking it matches its digest
+func (c *Cache) PutBlob(digest, mediaType string, data []byte) error {
+	if err := verify(digest, data); err != nil {
+		return err
+	}
+	path, err := c.blobPath(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(path+mediaTypeSuffix, []byte(mediaType)); err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// GetTag returns the entry for a tag reference, whether or not it is still fresh
+func (c *Cache) GetTag(reference string) (TagEntry, bool) {
+	var entry TagEntry
+	if !readJSON(c.entryPath(tagsDir, reference), &entry) || entry.Reference != reference {
+		return TagEntry{}, false
+	}
+	return entry, true
+}
+
+// PutTag records the digest a tag reference points to now
+func (c *Cache) PutTag(reference, digest, mediaType string) error {
+	data, err := json.Marshal(TagEntry{
+		Reference: reference,
+		Digest:    digest,
+		MediaType: mediaType,
+		Fetched:   c.now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	return writeFile(c.entryPath(tagsDir, reference), data)
+}
+
+// GetTagList returns the tags listed for a repository, whether or not the list is still fresh
+func (c *Cache) GetTagList(repository string) (TagListEntry, bool) {
+	var entry TagListEntry
+	if !readJSON(c.entryPath(listsDir, repository), &entry) || entry.Repository != repository {
+		return TagListEntry{}, false
+	}
+	return entry, true
+}
+
+// PutTagList records the tags a repository has now
+func (c *Cache) PutTagList(repository string, tags []string) error {
+	data, err := json.Marshal(TagListEntry{
+		Repository: repository,
+		Tags:       tags,
+		Fetched:    c.now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	return writeFile(c.entryPath(listsDir, repository), data)
+}
+
+// Info summarizes the contents of the cache
+type Info struct {
+	Tags        int   // Tag entries and tag lists
+	ExpiredTags int   // Tag entries and tag lists older than the TTL
+	Blobs       int   // Manifests and configs
+	Size        int64 // Total size of the cache in bytes
+}
+
+// Info counts the entries in the cache
+func (c *Cache) Info() (Info, error) {
+	info := Info{}
+	err := c.walk(func(path string, fileInfo fs.FileInfo) error {
+		info.Size += fileInfo.Size()
+		switch {
+		case strings.HasSuffix(path, mediaTypeSuffix):
+		case c.isEntryPath(path):
+			info.Tags++
+			if !c.freshEntry(path) {
+				info.ExpiredTags++
+			}
+		default:
+			info.Blobs++
+		}
+		return nil
+	})
+	return info, err
+}
+
+// Prune removes tag entries older than the TTL and blobs that have not been used within it.
+// It returns the number of tag entries and blobs removed.
+func (c *Cache) Prune() (int, error) {
+	removed := 0
+	err := c.walk(func(path string, fileInfo fs.FileInfo) error {
+		var expired bool
+		switch {
+		case strings.HasSuffix(path, mediaTypeSuffix):
+			return nil
+		case c.isEntryPath(path):
+			expired = !c.freshEntry(path)
+		default:
+			expired = c.now().Sub(fileInfo.ModTime()) >= c.ttl
+		}
+		if !expired {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		_ = os.Remove(path + mediaTypeSuffix)
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// Clear removes everything in the cache
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return nil
+}
+
+// walk calls fn for every file in the cache, doing nothing if the cache doesn't exist yet
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip directories and writes still in progress
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			return nil
+		}
+		// Files removed while walking (e.g., media types pruned with their blob) are skipped
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// freshEntry reports whether the tag entry or tag list in a file is still fresh
+func (c *Cache) freshEntry(path string) bool {
+	var entry struct {
+		Fetched time.Time `json:"fetched"`
+	}
+	return readJSON(path, &entry) && c.Fresh(entry.Fetched)
+}
+
+// readJSON decodes a JSON file, reporting whether it could be read
+func readJSON(path string, v any) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// blobPath returns where a blob is stored, rejecting malformed digests
+func (c *Cache) blobPath(digest string) (string, error) {
+	algorithm, encoded, found := strings.Cut(digest, ":")
+	if !found || newHash(algorithm) == nil {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+	if _, err := hex.DecodeString(encoded); err != nil || encoded == "" {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(c.dir, blobsDir, algorithm, encoded), nil
+}
+
+// entryPath returns where the tag entry or tag list for a name is stored
+func (c *Cache) entryPath(dir, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(c.dir, dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// isEntryPath reports whether a file in the cache is a tag entry or tag list, rather than a blob
+func (c *Cache) isEntryPath(path string) bool {
+	dir := filepath.Dir(path)
+	return dir == filepath.Join(c.dir, tagsDir) || dir == filepath.Join(c.dir, listsDir)
+}
+
+// newHash returns the hash for a digest algorithm, or nil if it isn't supported
+func newHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// verify checks that data matches a digest
+func verify(digest string, data []byte) error {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	h := newHash(algorithm)
+	if h == nil {
+		return fmt.Errorf("unsupported digest %q", digest)
+	}
+	h.Write(data)
+	if hex.EncodeToString(h.Sum(nil)) != encoded {
+		return fmt.Errorf("content does not match digest %s", digest)
+	}
+	return nil
+}
+
+// writeFile writes a file atomically, so concurrent readers never see a partial write
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
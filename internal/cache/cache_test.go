@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testDigest returns the sha256 digest of data
+func testDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newTestCache creates a cache in a temporary directory with a controllable clock
+func newTestCache(t *testing.T, now *time.Time) *Cache {
+	c := New(filepath.Join(t.TempDir(), "cache"), time.Hour)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	data := []byte(`{"schemaVersion":2}`)
+	digest := testDigest(data)
+	if err := c.PutBlob(digest, "application/vnd.oci.image.index.v1+json", data); err != nil {
+		t.Fatalf("PutBlob returned an error: %v", err)
+	}
+
+	mediaType, cached, ok := c.GetBlob(digest)
+	if !ok {
+		t.Fatal("Expected the blob to be cached")
+	}
+	if string(cached) != string(data) || mediaType != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("Expected the stored blob and media type, got %q (%s)", cached, mediaType)
+	}
+}
+
+func TestPutBlobRejectsMismatchedDigest(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	if err := c.PutBlob(testDigest([]byte("a")), "", []byte("b")); err == nil {
+		t.Error("Expected an error for content that doesn't match its digest")
+	}
+}
+
+func TestGetBlobIgnoresCorruption(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	data := []byte("config")
+	digest := testDigest(data)
+	if err := c.PutBlob(digest, "", data); err != nil {
+		t.Fatalf("PutBlob returned an error: %v", err)
+	}
+
+	path, _ := c.blobPath(digest)
+	if err := os.WriteFile(path, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt blob: %v", err)
+	}
+
+	if _, _, ok := c.GetBlob(digest); ok {
+		t.Error("Expected a corrupted blob to be treated as missing")
+	}
+}
+
+func TestTagFreshness(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	if err := c.PutTag("docker.io/library/busybox:latest", "sha256:aaaa", "application/vnd.oci.image.index.v1+json"); err != nil {
+		t.Fatalf("PutTag returned an error: %v", err)
+	}
+
+	entry, ok := c.GetTag("docker.io/library/busybox:latest")
+	if !ok || entry.Digest != "sha256:aaaa" {
+		t.Fatalf("Expected the tag entry to be cached, got %+v", entry)
+	}
+	if !c.Fresh(entry.Fetched) {
+		t.Error("Expected a new entry to be fresh")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if c.Fresh(entry.Fetched) {
+		t.Error("Expected the entry to expire after the TTL")
+	}
+	if _, ok := c.GetTag("docker.io/library/busybox:latest"); !ok {
+		t.Error("Expected expired entries to still be returned")
+	}
+}
+
+func TestInfoAndPrune(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	oldData := []byte("old")
+	if err := c.PutBlob(testDigest(oldData), "", oldData); err != nil {
+		t.Fatalf("PutBlob returned an error: %v", err)
+	}
+	if err := c.PutTag("docker.io/library/busybox:1.35", testDigest(oldData), ""); err != nil {
+		t.Fatalf("PutTag returned an error: %v", err)
+	}
+	oldPath, _ := c.blobPath(testDigest(oldData))
+	if err := os.Chtimes(oldPath, now, now); err != nil {
+		t.Fatalf("Failed to set blob time: %v", err)
+	}
+
+	// Two hours later, a new tag and blob are stored
+	now = now.Add(2 * time.Hour)
+	newData := []byte("new")
+	if err := c.PutBlob(testDigest(newData), "", newData); err != nil {
+		t.Fatalf("PutBlob returned an error: %v", err)
+	}
+	newPath, _ := c.blobPath(testDigest(newData))
+	if err := os.Chtimes(newPath, now, now); err != nil {
+		t.Fatalf("Failed to set blob time: %v", err)
+	}
+	if err := c.PutTag("docker.io/library/busybox:1.36", testDigest(newData), ""); err != nil {
+		t.Fatalf("PutTag returned an error: %v", err)
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatalf("Info returned an error: %v", err)
+	}
+	if info.Tags != 2 || info.ExpiredTags != 1 || info.Blobs != 2 || info.Size == 0 {
+		t.Errorf("Expected 2 tags (1 expired) and 2 blobs, got %+v", info)
+	}
+
+	removed, err := c.Prune()
+	if err != nil {
+		t.Fatalf("Prune returned an error: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected the old tag and blob to be removed, got %d removed", removed)
+	}
+	if _, _, ok := c.GetBlob(testDigest(newData)); !ok {
+		t.Error("Expected the recently used blob to be kept")
+	}
+	if _, ok := c.GetTag("docker.io/library/busybox:1.36"); !ok {
+		t.Error("Expected the fresh tag to be kept")
+	}
+}
+
+func TestClear(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	if err := c.PutTag("docker.io/library/busybox:latest", "sha256:aaaa", ""); err != nil {
+		t.Fatalf("PutTag returned an error: %v", err)
+	}
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear returned an error: %v", err)
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatalf("Info returned an error on a cleared cache: %v", err)
+	}
+	if info.Tags != 0 {
+		t.Errorf("Expected an empty cache, got %+v", info)
+	}
+}
+
+func TestTagListRoundTrip(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	if err := c.PutTagList("docker.io/library/postgres", []string{"16.3", "16.4"}); err != nil {
+		t.Fatalf("PutTagList returned an error: %v", err)
+	}
+
+	entry, ok := c.GetTagList("docker.io/library/postgres")
+	if !ok || len(entry.Tags) != 2 || !c.Fresh(entry.Fetched) {
+		t.Errorf("Expected 2 fresh tags, got %+v", entry)
+	}
+	if _, ok := c.GetTagList("docker.io/library/busybox"); ok {
+		t.Error("Expected no tag list for another repository")
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatalf("Info returned an error: %v", err)
+	}
+	if info.Tags != 1 || info.Blobs != 0 {
+		t.Errorf("Expected the tag list to be counted with tags, got %+v", info)
+	}
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
 )
 
 // LoadContainersConfig loads container configuration from a TOML file
@@ -13,5 +17,179 @@ func LoadContainersConfig(path string) (*models.ContainersConfig, error) {
 	if _, err := toml.DecodeFile(path, config); err != nil {
 		return nil, fmt.Errorf("failed to decode containers config: %w", err)
 	}
+
+	for i := range config.Containers {
+		container := &config.Containers[i]
+		if err := applyImage(container); err != nil {
+			return nil, fmt.Errorf("container %s: %w", container.Image, err)
+		}
+		if err := validateContainer(*container); err != nil {
+			return nil, fmt.Errorf("container %s/%s: %w", container.Repository, container.Name, err)
+		}
+	}
+	if err := validateMirrors(config.Mirrors); err != nil {
+		return nil, err
+	}
+	for name, registry := range config.Registries {
+		if err := validateRegistry(registry); err != nil {
+			return nil, fmt.Errorf("registry %s: %w", name, err)
+		}
+	}
 	return config, nil
 }
+
+// applyImage fills in a container's repository, name, tag and digest from its image reference.
+// Without a tag, the image is pinned from latest like in docker pull, unless its tag is picked
+// by a constraint or pattern. A digest pins the tag, which is still needed as the output key.
+func applyImage(container *models.Container) error {
+	if container.Image == "" {
+		return nil
+	}
+	if container.Repository != "" || container.Name != "" {
+		return fmt.Errorf("image can't be used with repository or name")
+	}
+
+	image, err := models.ParseImage(container.Image)
+	if err != nil {
+		return err
+	}
+	pickedTag := container.TagConstraint != "" || container.TagRegex != ""
+	switch {
+	case image.Tag != "" && container.Tag != "":
+		return fmt.Errorf("image already has a tag, so tag can't be set")
+	case image.Tag != "" && pickedTag:
+		return fmt.Errorf("image with a tag can't be used with tag_constraint or tag_regex")
+	case image.Digest != "" && image.Tag == "" && container.Tag == "":
+		return fmt.Errorf("image with a digest needs a tag too, used as the output key (e.g., busybox:1.36@sha256:... or tag = \"1.36\")")
+	case image.Digest != "" && container.Source != "":
+		return fmt.Errorf("image with a digest can't be used with source")
+	}
+
+	container.Repository = image.Repository
+	container.Name = image.Name
+	container.Digest = image.Digest
+	switch {
+	case image.Tag != "":
+		container.Tag = image.Tag
+	case container.Tag == "" && !pickedTag:
+		container.Tag = "latest"
+	}
+	return nil
+}
+
+// validateVerify checks that signatures are verified either with a key or keylessly, that keyless
+// verification names the signer and everything needed to trust its certificate, and that both
+// know the transparency log signatures must be recorded in
+func validateVerify(verify models.VerifyConfig) error {
+	keyless := verify.Identity != "" || verify.IdentityRegex != "" || verify.Issuer != "" || verify.Roots != ""
+	switch {
+	case verify.Key != "" && keyless:
+		return fmt.Errorf("verify key can't be used with identity, identity_regex, issuer or roots")
+	case verify.Key != "" && verify.RekorKey == "":
+		return fmt.Errorf("verify key needs rekor_key to check the transparency log bundle")
+	case verify.Key != "":
+		return nil
+	case verify.Identity == "" && verify.IdentityRegex == "":
+		return fmt.Errorf("verify needs a key, or an identity or identity_regex for keyless signatures")
+	case verify.Identity != "" && verify.IdentityRegex != "":
+		return fmt.Errorf("verify identity and identity_regex can't be used together")
+	case verify.Issuer == "" || verify.Roots == "" || verify.RekorKey == "":
+		return fmt.Errorf("keyless verify needs issuer, roots and rekor_key")
+	}
+
+	if _, err := regexp.Compile(verify.IdentityRegex); err != nil {
+		return fmt.Errorf("invalid identity_regex %q: %w", verify.IdentityRegex, err)
+	}
+	return nil
+}
+
+// validateRegistry checks that a registry's credentials are complete and each secret has one source
+func validateRegistry(registry models.RegistryConfig) error {
+	if registry.Password != nil && registry.Username == "" {
+		return fmt.Errorf("password requires a username")
+	}
+	if registry.Password != nil && registry.Token != nil {
+		return fmt.Errorf("password and token can't be used together")
+	}
+	if !slices.Contains([]string{"", "enabled", "insecure", "disabled"}, registry.TLS) {
+		return fmt.Errorf("invalid tls %q: must be enabled, insecure or disabled", registry.TLS)
+	}
+	if (registry.ClientCert == "") != (registry.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be given together")
+	}
+	if registry.TLS == "disabled" && (registry.CAFile != "" || registry.ClientCert != "") {
+		return fmt.Errorf("ca_file and client certificates can't be used with tls disabled")
+	}
+
+	secrets := map[string]*models.SecretSource{"password": registry.Password, "token": registry.Token}
+	for name, source := range secrets {
+		if source == nil {
+			continue
+		}
+		sources := 0
+		for _, set := range []bool{source.Env != "", source.File != "", len(source.Command) > 0} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("%s needs exactly one of env, file or command", name)
+		}
+	}
+	return nil
+}
+
+// validateMirrors checks that every mirrored registry has mirrors other than itself
+func validateMirrors(mirrors map[string][]string) error {
+	for registry, hosts := range mirrors {
+		if len(hosts) == 0 {
+			return fmt.Errorf("mirrors for %s: at least one mirror is required", registry)
+		}
+		if slices.Contains(hosts, registry) {
+			return fmt.Errorf("mirrors for %s: a registry can't mirror itself", registry)
+		}
+	}
+	return nil
+}
+
+// validateContainer checks that the ways of picking a container's tag are combined sensibly
+func validateContainer(container models.Container) error {
+	switch {
+	case container.Tag == "" && container.TagConstraint == "" && container.TagRegex == "":
+		return fmt.Errorf("needs a tag, tag_constraint or tag_regex")
+	case container.TagConstraint != "" && container.TagRegex != "":
+		return fmt.Errorf("tag_constraint and tag_regex can't be used together")
+	case container.TagSuffix != "" && container.TagConstraint == "":
+		return fmt.Errorf("tag_suffix is only used with tag_constraint")
+	case container.TagSort != "" && container.TagRegex == "":
+		return fmt.Errorf("tag_sort is only used with tag_regex")
+	case container.TagSort != "" && !slices.Contains(tags.SortStrategies, container.TagSort):
+		return fmt.Errorf("invalid tag_sort %q: must be one of %s", container.TagSort, strings.Join(tags.SortStrategies, ", "))
+	case container.MediaTypes != "" && !slices.Contains(models.MediaTypePolicies, container.MediaTypes):
+		return fmt.Errorf("invalid media_types %q: must be one of %s", container.MediaTypes, strings.Join(models.MediaTypePolicies, ", "))
+	}
+
+	// Local images are read as they are, so their tag can't be picked from a tag list
+	if container.Source != "" {
+		if _, err := models.ParseSource(container.Source); err != nil {
+			return err
+		}
+		if container.Tag == "" || container.TagConstraint != "" || container.TagRegex != "" || container.VersionRegex != "" {
+			return fmt.Errorf("source needs a tag and can't be used with tag_constraint, tag_regex or version_regex")
+		}
+	}
+
+	if container.Verify != nil {
+		if err := validateVerify(*container.Verify); err != nil {
+			return err
+		}
+	}
+
+	// Catch invalid patterns before any registry is contacted
+	for _, pattern := range []string{container.TagRegex, container.VersionRegex} {
+		if _, err := tags.Match(nil, pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
 )
 
 func TestLoadContainersConfig(t *testing.T) {
@@ -56,3 +58,280 @@ architectures = ["linux/amd64"]
 		t.Errorf("Expected 2 architectures for first container, got %d", len(config.Containers[0].Architectures))
 	}
 }
+
+func TestLoadContainersConfigAllowFallback(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test-containers.toml")
+	tomlContent := `
+[[containers]]
+repository = "docker.io"
+name = "library/busybox"
+tag = "latest"
+
+[[containers]]
+repository = "ghcr.io"
+name = "user/repo"
+tag = "1.0.0"
+architectures = ["linux/amd64"]
+allow_fallback = true
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := LoadContainersConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadContainersConfig returned an error: %v", err)
+	}
+	if config.Containers[0].AllowFallback || !config.Containers[1].AllowFallback {
+		t.Errorf("Expected allow_fallback to be set only on the second container")
+	}
+}
+
+func TestLoadContainersConfigTagConstraint(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test-containers.toml")
+	tomlContent := `
+[[containers]]
+repository = "docker.io"
+name = "library/postgres"
+tag_constraint = ">=16 <17"
+tag_suffix = "-alpine"
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := LoadContainersConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadContainersConfig returned an error: %v", err)
+	}
+	if config.Containers[0].TagConstraint != ">=16 <17" || config.Containers[0].TagSuffix != "-alpine" {
+		t.Errorf("Expected tag constraint \">=16 <17\" with suffix \"-alpine\", got %q with suffix %q",
+			config.Containers[0].TagConstraint, config.Containers[0].TagSuffix)
+	}
+}
+
+func TestLoadContainersConfigRegistries(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test-containers.toml")
+	tomlContent := `
+[[containers]]
+repository = "ghcr.io"
+name = "user/repo"
+tag = "1.0.0"
+
+[registries."ghcr.io"]
+username = "user"
+password = { env = "GHCR_TOKEN" }
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := LoadContainersConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadContainersConfig returned an error: %v", err)
+	}
+	if registry := config.Registries["ghcr.io"]; registry.Username != "user" || registry.Password == nil || registry.Password.Env != "GHCR_TOKEN" {
+		t.Errorf("Expected ghcr.io credentials for user from GHCR_TOKEN, got %+v", registry)
+	}
+}
+
+func TestValidateRegistry(t *testing.T) {
+	tests := []struct {
+		registry models.RegistryConfig
+		valid    bool
+	}{
+		{models.RegistryConfig{Username: "user", Password: &models.SecretSource{Env: "TOKEN"}}, true},
+		{models.RegistryConfig{Token: &models.SecretSource{Command: []string{"pass", "show", "registry"}}}, true},
+		{models.RegistryConfig{Password: &models.SecretSource{Env: "TOKEN"}}, false},
+		{models.RegistryConfig{Username: "user", Password: &models.SecretSource{}}, false},
+		{models.RegistryConfig{Username: "user", Password: &models.SecretSource{Env: "TOKEN", File: "/run/secrets/token"}}, false},
+		{models.RegistryConfig{Username: "user", Password: &models.SecretSource{Env: "A"}, Token: &models.SecretSource{Env: "B"}}, false},
+		{models.RegistryConfig{TLS: "insecure", CAFile: "/etc/ssl/lab-ca.pem"}, true},
+		{models.RegistryConfig{TLS: "disabled"}, true},
+		{models.RegistryConfig{TLS: "plain"}, false},
+		{models.RegistryConfig{ClientCert: "/etc/ssl/client.pem"}, false},
+		{models.RegistryConfig{TLS: "disabled", CAFile: "/etc/ssl/lab-ca.pem"}, false},
+	}
+
+	for _, test := range tests {
+		err := validateRegistry(test.registry)
+		if test.valid && err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", test.registry, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected %+v to be invalid", test.registry)
+		}
+	}
+}
+
+func TestLoadContainersConfigMirrors(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test-containers.toml")
+	tomlContent := `
+[[containers]]
+repository = "docker.io"
+name = "library/busybox"
+tag = "latest"
+
+[mirrors]
+"docker.io" = ["mirror.internal:5000", "mirror-backup.internal"]
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := LoadContainersConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadContainersConfig returned an error: %v", err)
+	}
+	if mirrors := config.Mirrors["docker.io"]; len(mirrors) != 2 || mirrors[0] != "mirror.internal:5000" {
+		t.Errorf("Expected 2 docker.io mirrors starting with mirror.internal:5000, got %v", mirrors)
+	}
+}
+
+func TestValidateMirrors(t *testing.T) {
+	if err := validateMirrors(map[string][]string{"docker.io": {"mirror.internal"}}); err != nil {
+		t.Errorf("Expected mirrors to be valid, got %v", err)
+	}
+	if err := validateMirrors(map[string][]string{"docker.io": {}}); err == nil {
+		t.Error("Expected an error for a registry without mirrors")
+	}
+	if err := validateMirrors(map[string][]string{"docker.io": {"docker.io"}}); err == nil {
+		t.Error("Expected an error for a registry mirroring itself")
+	}
+}
+
+func TestLoadContainersConfigWithoutTag(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test-containers.toml")
+
+	tomlContent := `
+[[containers]]
+repository = "docker.io"
+name = "library/busybox"
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := LoadContainersConfig(tmpFile); err == nil {
+		t.Error("Expected an error for a container without a tag or tag_constraint")
+	}
+}
+
+func TestValidateContainer(t *testing.T) {
+	tests := []struct {
+		container models.Container
+		valid     bool
+	}{
+		{models.Container{Tag: "latest"}, true},
+		{models.Container{TagConstraint: ">=16", TagSuffix: "-alpine"}, true},
+		{models.Container{TagRegex: `\d+`, TagSort: "numeric"}, true},
+		{models.Container{}, false},
+		{models.Container{TagConstraint: ">=16", TagRegex: `\d+`}, false},
+		{models.Container{Tag: "latest", TagSuffix: "-alpine"}, false},
+		{models.Container{Tag: "latest", TagSort: "numeric"}, false},
+		{models.Container{TagRegex: `\d+`, TagSort: "newest"}, false},
+		{models.Container{Tag: "latest", VersionRegex: `\d+\.\d+\.\d+`}, true},
+		{models.Container{Tag: "latest", VersionRegex: `(`}, false},
+		{models.Container{Tag: "1.0", Source: "ocidir://build/app"}, true},
+		{models.Container{Tag: "1.0", Source: "build/app"}, false},
+		{models.Container{Tag: "16", TagConstraint: ">=16", Source: "oci-archive:app.tar"}, false},
+		{models.Container{Tag: "latest", VersionRegex: `\d+`, Source: "docker-archive:app.tar"}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{Key: "cosign.pub", RekorKey: "rekor.pub"}}, true},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{Key: "cosign.pub"}}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{
+			IdentityRegex: `https://github\.com/example/.*`, Issuer: "https://token.actions.githubusercontent.com", Roots: "fulcio.pem", RekorKey: "rekor.pub",
+		}}, true},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{}}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{Key: "cosign.pub", Issuer: "https://accounts.google.com"}}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{Identity: "me@example.com", Issuer: "https://accounts.google.com"}}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{
+			Identity: "me@example.com", IdentityRegex: ".*", Issuer: "https://accounts.google.com", Roots: "fulcio.pem", RekorKey: "rekor.pub",
+		}}, false},
+		{models.Container{Tag: "latest", Verify: &models.VerifyConfig{
+			IdentityRegex: "(", Issuer: "https://accounts.google.com", Roots: "fulcio.pem", RekorKey: "rekor.pub",
+		}}, false},
+		{models.Container{Tag: "latest", MediaTypes: "oci"}, true},
+		{models.Container{Tag: "latest", MediaTypes: "schema1"}, false},
+	}
+
+	for _, test := range tests {
+		err := validateContainer(test.container)
+		if test.valid && err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", test.container, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected %+v to be invalid", test.container)
+		}
+	}
+}
+
+func TestLoadContainersConfigWithImage(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "containers.toml")
+	tomlContent := `
+[[containers]]
+image = "busybox:1.36"
+
+[[containers]]
+image = "postgres"
+tag_constraint = ">=16 <17"
+
+[[containers]]
+repository = "ghcr.io"
+name = "user/repo"
+tag = "1.0.0"
+`
+	if err := os.WriteFile(tmpFile, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := LoadContainersConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadContainersConfig returned an error: %v", err)
+	}
+
+	expected := []string{"docker.io/library/busybox:1.36", "docker.io/library/postgres:", "ghcr.io/user/repo:1.0.0"}
+	for i, container := range config.Containers {
+		if reference := container.Repository + "/" + container.Name + ":" + container.Tag; reference != expected[i] {
+			t.Errorf("Expected container %d to be %s, got %s", i, expected[i], reference)
+		}
+	}
+}
+
+func TestApplyImage(t *testing.T) {
+	const pinned = "busybox:1.36@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74"
+
+	tests := []struct {
+		container models.Container
+		expected  string
+	}{
+		{models.Container{Image: "busybox"}, "docker.io/library/busybox:latest"},
+		{models.Container{Image: "ghcr.io/example/app", Tag: "2.0"}, "ghcr.io/example/app:2.0"},
+		{models.Container{Image: "grafana/grafana", TagConstraint: ">=11"}, `docker.io/grafana/grafana (tag ">=11")`},
+		{models.Container{Image: pinned}, "docker.io/library/busybox:1.36@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74"},
+		{models.Container{Image: "busybox", Repository: "docker.io"}, ""},
+		{models.Container{Image: "busybox:1.36", Tag: "1.37"}, ""},
+		{models.Container{Image: "postgres:16", TagConstraint: ">=16"}, ""},
+		{models.Container{Image: "busybox@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74", Tag: "1.36"},
+			"docker.io/library/busybox:1.36@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74"},
+		{models.Container{Image: "busybox@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74"}, ""},
+		{models.Container{Image: "busybox@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74", TagConstraint: ">=1"}, ""},
+		{models.Container{Image: pinned, Source: "ocidir://build/busybox"}, ""},
+		{models.Container{Image: "Busybox"}, ""},
+	}
+
+	for _, test := range tests {
+		container := test.container
+		err := applyImage(&container)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", test.container)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %+v: %v", test.container, err)
+		} else if container.Reference() != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, container.Reference())
+		}
+	}
+}
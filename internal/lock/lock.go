@@ -0,0 +1,211 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+// Load reads a lock file, returning an empty lock if the file does not exist yet
+func Load(path string) (*models.Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &models.Lock{Entries: map[string]models.LockEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	lock := &models.Lock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file: %w", err)
+	}
+	if lock.Entries == nil {
+		lock.Entries = map[string]models.LockEntry{}
+	}
+	return lock, nil
+}
+
+// LoadOutput reads the digests from a JSON output file written by a previous run, so they
+// can stand in for a lock. The index digest is only known if it was written under indexKey,
+// and entries never cover all platforms since the output doesn't say whether they did.
+// A missing file gives an empty lock.
+func LoadOutput(path, indexKey string) (*models.Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &models.Lock{Entries: map[string]models.LockEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+
+	var results models.NestedDigestResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode output file: %w", err)
+	}
+	return outputLock(results, indexKey), nil
+}
+
+// nixLinePattern matches a line of the Nix output: an attribute opening a set, or holding a string
+var nixLinePattern = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)" = (?:\{|"((?:[^"\\]|\\.)*)";)$`)
+
+// LoadNixOutput reads the digests from a Nix output file written by a previous run, like
+// LoadOutput. Only the layout this tool writes is understood, one attribute per line;
+// the metadata of each tag is skipped. A missing file gives an empty lock.
+func LoadNixOutput(path, indexKey string) (*models.Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &models.Lock{Entries: map[string]models.LockEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+
+	results := models.NestedDigestResults{}
+	// Attribute sets opened above the current line: registry, repository, tag, then metadata
+	sets := []string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (i == 0 && line == "{...}: {") {
+			continue
+		}
+		if line == "};" || line == "}" {
+			if len(sets) > 0 {
+				sets = sets[:len(sets)-1]
+			}
+			continue
+		}
+
+		match := nixLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("failed to decode output file: unexpected line %d: %s", i+1, line)
+		}
+		if strings.HasSuffix(line, "{") {
+			sets = append(sets, unescapeNixString(match[1]))
+			continue
+		}
+		if len(sets) != 3 {
+			continue
+		}
+		registry, name, tag := sets[0], sets[1], sets[2]
+		if results[registry] == nil {
+			results[registry] = models.RepositoryMap{}
+		}
+		if results[registry][name] == nil {
+			results[registry][name] = models.TagMap{}
+		}
+		if results[registry][name][tag] == nil {
+			results[registry][name][tag] = models.ArchMap{}
+		}
+		results[registry][name][tag][unescapeNixString(match[1])] = unescapeNixString(match[2])
+	}
+	return outputLock(results, indexKey), nil
+}
+
+// unescapeNixString undoes the escaping of strings in the Nix output
+func unescapeNixString(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// outputLock turns the digests of a previous output into lock entries
+func outputLock(results models.NestedDigestResults, indexKey string) *models.Lock {
+	lock := &models.Lock{Entries: map[string]models.LockEntry{}}
+	for registry, repositories := range results {
+		for name, tags := range repositories {
+			for tag, archs := range tags {
+				entry := models.LockEntry{Platforms: models.ArchMap{}}
+				for key, value := range archs {
+					// Digests are written as full references (registry/name@digest)
+					_, digest, _ := strings.Cut(value, "@")
+					switch {
+					case key == models.TagKey:
+						tag = value
+					case key == models.VersionKey:
+						entry.VersionTag = value
+					case key == indexKey:
+						entry.Digest = digest
+					case strings.Contains(key, "/") && digest != "":
+						entry.Platforms[key] = digest
+					}
+				}
+				lock.Entries[models.LockKey(registry, name, tag)] = entry
+			}
+		}
+	}
+	return lock
+}
+
+// RichOutputLock turns the digests of a previous rich output into lock entries, like LoadOutput.
+// The rich output always records the index digest.
+func RichOutputLock(results models.RichResults) *models.Lock {
+	lock := &models.Lock{Entries: map[string]models.LockEntry{}}
+	for registry, repositories := range results {
+		for name, tags := range repositories {
+			for tag, rich := range tags {
+				entry := models.LockEntry{Digest: rich.Digest, Platforms: models.ArchMap{}, VersionTag: rich.Version}
+				for key, platform := range rich.Platforms {
+					entry.Platforms[key] = platform.Digest
+				}
+				if rich.Tag != "" {
+					tag = rich.Tag
+				}
+				lock.Entries[models.LockKey(registry, name, tag)] = entry
+			}
+		}
+	}
+	return lock
+}
+
+// LoadRichOutput reads a rich JSON output file written by a previous run, so the sizes it
+// recorded can be compared with the new ones. A missing file gives empty results.
+func LoadRichOutput(path string) (models.RichResults, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return models.RichResults{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+
+	var results models.RichResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode output file: %w", err)
+	}
+	return results, nil
+}
+
+// Save writes a lock file, creating parent directories if they don't exist
+func Save(path string, lock *models.Lock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create lock directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
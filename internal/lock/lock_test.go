@@ -0,0 +1,203 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+func TestLoadMissingLock(t *testing.T) {
+	lock, err := Load(filepath.Join(t.TempDir(), "missing.lock.json"))
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if len(lock.Entries) != 0 {
+		t.Errorf("Expected an empty lock, got %d entries", len(lock.Entries))
+	}
+}
+
+func TestSaveAndLoadLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "containers.lock.json")
+
+	original := models.NewLock(models.TagResults{
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "latest",
+			Digest:     "sha256:aaaa",
+			Platforms:  models.ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+	})
+
+	if err := Save(path, original); err != nil {
+		t.Fatalf("Save returned an error: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(original, loaded) {
+		t.Errorf("Expected loaded lock to match saved lock, got %+v", loaded)
+	}
+
+	entry, exists := loaded.Entries["docker.io/library/busybox:latest"]
+	if !exists {
+		t.Fatal("Expected entry for docker.io/library/busybox:latest")
+	}
+	if entry.Digest != "sha256:aaaa" {
+		t.Errorf("Expected digest sha256:aaaa, got %s", entry.Digest)
+	}
+}
+
+func TestLoadOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.json")
+	output := `{
+  "docker.io": {
+    "library/busybox": {
+      "latest": {
+        "_index": "docker.io/library/busybox@sha256:aaaa",
+        "_version": "1.37.0",
+        "linux/amd64": "docker.io/library/busybox@sha256:bbbb"
+      }
+    },
+    "library/postgres": {
+      "16": {
+        "_tag": "16.4",
+        "linux/arm64": "docker.io/library/postgres@sha256:cccc"
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+
+	loaded, err := LoadOutput(path, models.DefaultIndexKey)
+	if err != nil {
+		t.Fatalf("LoadOutput returned an error: %v", err)
+	}
+
+	expected := map[string]models.LockEntry{
+		"docker.io/library/busybox:latest": {
+			Digest:     "sha256:aaaa",
+			VersionTag: "1.37.0",
+			Platforms:  models.ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+		// Constraint entries are keyed by the concrete tag, like in a lock
+		"docker.io/library/postgres:16.4": {
+			Platforms: models.ArchMap{"linux/arm64": "sha256:cccc"},
+		},
+	}
+	if !reflect.DeepEqual(loaded.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded.Entries)
+	}
+}
+
+func TestLoadOutputMissing(t *testing.T) {
+	loaded, err := LoadOutput(filepath.Join(t.TempDir(), "missing.json"), models.DefaultIndexKey)
+	if err != nil {
+		t.Fatalf("LoadOutput returned an error: %v", err)
+	}
+	if len(loaded.Entries) != 0 {
+		t.Errorf("Expected an empty lock, got %d entries", len(loaded.Entries))
+	}
+}
+
+func TestLoadRichOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.json")
+	output := `{
+  "docker.io": {
+    "library/busybox": {
+      "1.36": {
+        "digest": "sha256:aaaa",
+        "platforms": {
+          "linux/arm64": {
+            "digest": "sha256:bbbb",
+            "reference": "docker.io/library/busybox@sha256:bbbb",
+            "size": 1933214
+          }
+        }
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+
+	loaded, err := LoadRichOutput(path)
+	if err != nil {
+		t.Fatalf("LoadRichOutput returned an error: %v", err)
+	}
+	if size := loaded["docker.io"]["library/busybox"]["1.36"].Platforms["linux/arm64"].Size; size != 1933214 {
+		t.Errorf("Expected size 1933214, got %d", size)
+	}
+
+	missing, err := LoadRichOutput(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || len(missing) != 0 {
+		t.Errorf("Expected empty results for a missing file, got %v (%v)", missing, err)
+	}
+}
+
+func TestLoadNixOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "containers.nix")
+	output := `{...}: {
+  "docker.io" = {
+    "library/postgres" = {
+      "16" = {
+        "_tag" = "16.4";
+        "linux/arm64" = "docker.io/library/postgres@sha256:cccc";
+      };
+    };
+  };
+}`
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+
+	loaded, err := LoadNixOutput(path, models.DefaultIndexKey)
+	if err != nil {
+		t.Fatalf("LoadNixOutput returned an error: %v", err)
+	}
+	expected := map[string]models.LockEntry{
+		"docker.io/library/postgres:16.4": {Platforms: models.ArchMap{"linux/arm64": "sha256:cccc"}},
+	}
+	if !reflect.DeepEqual(loaded.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded.Entries)
+	}
+
+	// Nix files edited by hand are not understood
+	if err := os.WriteFile(path, []byte(`{ images = import ./images.nix; }`), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+	if _, err := LoadNixOutput(path, models.DefaultIndexKey); err == nil {
+		t.Error("Expected an error for a Nix file this tool didn't write")
+	}
+}
+
+func TestRichOutputLock(t *testing.T) {
+	results := models.RichResults{
+		"docker.io": {"library/busybox": {"1": {
+			Tag:       "1.36",
+			Version:   "1.36.1",
+			Digest:    "sha256:aaaa",
+			Platforms: map[string]models.RichPlatform{"linux/arm64": {Digest: "sha256:bbbb"}},
+		}}},
+	}
+
+	expected := map[string]models.LockEntry{
+		"docker.io/library/busybox:1.36": {
+			Digest:     "sha256:aaaa",
+			VersionTag: "1.36.1",
+			Platforms:  models.ArchMap{"linux/arm64": "sha256:bbbb"},
+		},
+	}
+	if lock := RichOutputLock(results); !reflect.DeepEqual(lock.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, lock.Entries)
+	}
+}
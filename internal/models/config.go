@@ -1,16 +1,151 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
+// AllArchitectures is the architectures value that selects every platform in an image
+const AllArchitectures = "all"
+
 // ContainersConfig represents the structure of containers.toml file
 type ContainersConfig struct {
-	Containers []Container `toml:"containers"` // List of containers to fetch digests for
+	Containers []Container               `toml:"containers"` // List of containers to fetch digests for
+	Mirrors    map[string][]string       `toml:"mirrors"`    // Mirrors to query instead of each upstream registry, in fallback order
+	Registries map[string]RegistryConfig `toml:"registries"` // Connection settings keyed by registry hostname
+}
+
+// RegistryConfig holds the settings for connecting to a single registry
+type RegistryConfig struct {
+	Username   string        `toml:"username"`    // Username to log in with
+	Password   *SecretSource `toml:"password"`    // Where the password (or personal access token) is read from
+	Token      *SecretSource `toml:"token"`       // Where an identity token is read from, used instead of a password
+	TLS        string        `toml:"tls"`         // TLS mode: enabled (default), insecure to skip verification, or disabled for plain HTTP
+	CAFile     string        `toml:"ca_file"`     // PEM file with the CA certificates the registry certificate is signed by
+	ClientCert string        `toml:"client_cert"` // PEM file with a client certificate for mutual TLS
+	ClientKey  string        `toml:"client_key"`  // PEM file with the private key of the client certificate
+}
+
+// SecretSource tells where a secret is read from. Exactly one field must be set,
+// so secrets never have to be written into the config file itself.
+type SecretSource struct {
+	Env     string   `toml:"env"`     // Environment variable holding the secret
+	File    string   `toml:"file"`    // File holding the secret, with trailing newlines removed
+	Command []string `toml:"command"` // Command printing the secret on stdout, with its arguments
+}
+
+// CountRegistry returns the number of containers pulled from the given registry,
+// leaving out those read from a local source
+func (c *ContainersConfig) CountRegistry(registry string) int {
+	count := 0
+	for _, container := range c.Containers {
+		if container.Repository == registry && container.Source == "" {
+			count++
+		}
+	}
+	return count
 }
 
 // Container represents a container entry in the containers.toml file
 type Container struct {
-	Repository    string   `toml:"repository"`    // Repository hostname (e.g., docker.io, ghcr.io)
-	Name          string   `toml:"name"`          // Container name (e.g., library/busybox)
-	Tag           string   `toml:"tag"`           // Container tag (e.g., latest)
-	Architectures []string `toml:"architectures"` // List of architectures (e.g., "linux/amd64", "linux/arm/v5")
+	Image         string        `toml:"image"`          // Full image reference (e.g., busybox:1.36), instead of repository, name and tag
+	Repository    string        `toml:"repository"`     // Repository hostname (e.g., docker.io, ghcr.io)
+	Name          string        `toml:"name"`           // Container name (e.g., library/busybox)
+	Tag           string        `toml:"tag"`            // Container tag (e.g., latest), or the output key when a tag constraint is used
+	TagConstraint string        `toml:"tag_constraint"` // Semver constraint the tag is picked with (e.g., ">=16 <17")
+	TagSuffix     string        `toml:"tag_suffix"`     // Suffix a tag must have to match the constraint (e.g., "-alpine")
+	TagRegex      string        `toml:"tag_regex"`      // Pattern the tag is picked with (e.g., "\d{4}\.\d+\.\d+")
+	TagSort       string        `toml:"tag_sort"`       // How tags matching the pattern are ordered, semver by default
+	VersionRegex  string        `toml:"version_regex"`  // Pattern of the version tags to look up for the resolved digest (e.g., "\d+\.\d+\.\d+")
+	Architectures []string      `toml:"architectures"`  // List of architectures (e.g., "linux/amd64", "linux/arm/v5"), "all" or empty for every platform
+	AllowFallback bool          `toml:"allow_fallback"` // Use the index digest for architectures the image doesn't provide
+	Source        string        `toml:"source"`         // Local OCI layout or archive the image is read from before it is published (e.g., ocidir://build/app)
+	Verify        *VerifyConfig `toml:"verify"`         // Cosign signature the image must have, nil to accept unsigned images
+	MediaTypes    string        `toml:"media_types"`    // Manifest media types accepted for this container, overriding --media-types
+	Digest        string        `toml:"-"`              // Digest the image is pinned to, when its image reference has one
+}
+
+// VerifyConfig tells how a container's cosign signature is verified: with the public
+// key it was signed with, or keylessly with the identity that signed it
+type VerifyConfig struct {
+	Key           string `toml:"key"`            // PEM public key file (e.g., cosign.pub)
+	Identity      string `toml:"identity"`       // Email or URI the keyless signer's certificate must name
+	IdentityRegex string `toml:"identity_regex"` // Pattern the keyless signer's identity must match, instead of identity
+	Issuer        string `toml:"issuer"`         // OIDC issuer the keyless signer logged in with (e.g., https://token.actions.githubusercontent.com)
+	Roots         string `toml:"roots"`          // PEM file with the Fulcio root and intermediate certificates
+	RekorKey      string `toml:"rekor_key"`      // PEM public key of the Rekor transparency log signatures must be recorded in
+}
+
+// DefaultTagSort is the sort strategy used for tag_regex when tag_sort is not set
+const DefaultTagSort = "semver"
+
+// Media type policies, telling which kinds of manifests an image may be pinned to
+const (
+	MediaTypesOCI      = "oci"       // Only OCI manifests and indexes
+	MediaTypesDockerV2 = "docker-v2" // OCI manifests and indexes, and Docker v2 manifests and manifest lists
+	MediaTypesAny      = "any"       // Any manifest, including legacy Docker schema1 manifests
+)
+
+// MediaTypePolicies lists the media type policies, from the strictest
+var MediaTypePolicies = []string{MediaTypesOCI, MediaTypesDockerV2, MediaTypesAny}
+
+// DefaultMediaTypes is the media type policy used when none is configured, which rejects Docker schema1
+const DefaultMediaTypes = MediaTypesDockerV2
+
+// TagDescription describes the tag of a container for messages, which is
+// the constraint or pattern when the tag is picked from the repository's tags
+func (c Container) TagDescription() string {
+	switch {
+	case c.TagRegex != "":
+		return fmt.Sprintf("regex %q", c.TagRegex)
+	case c.TagConstraint != "" && c.TagSuffix != "":
+		return fmt.Sprintf("%q with suffix %q", c.TagConstraint, c.TagSuffix)
+	case c.TagConstraint != "":
+		return fmt.Sprintf("%q", c.TagConstraint)
+	}
+	return c.Tag
+}
+
+// Reference describes the container for messages, as registry/name:tag for literal tags
+// or followed by the constraint or pattern the tag is picked with, and by the local source if any
+func (c Container) Reference() string {
+	if c.Source != "" {
+		return fmt.Sprintf("%s/%s:%s from %s", c.Repository, c.Name, c.Tag, c.Source)
+	}
+	if c.Digest != "" {
+		return fmt.Sprintf("%s/%s:%s@%s", c.Repository, c.Name, c.Tag, c.Digest)
+	}
+	if c.TagConstraint == "" && c.TagRegex == "" {
+		return fmt.Sprintf("%s/%s:%s", c.Repository, c.Name, c.Tag)
+	}
+	return fmt.Sprintf("%s/%s (tag %s)", c.Repository, c.Name, c.TagDescription())
+}
+
+// TagSortStrategy returns how tags matching tag_regex are ordered
+func (c Container) TagSortStrategy() string {
+	if c.TagSort == "" {
+		return DefaultTagSort
+	}
+	return c.TagSort
+}
+
+// AllPlatforms reports whether every platform in the image should be pinned,
+// which is the case when architectures is omitted or contains "all"
+func (c Container) AllPlatforms() bool {
+	if len(c.Architectures) == 0 {
+		return true
+	}
+	for _, arch := range c.Architectures {
+		if IsAllArchitectures(arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllArchitectures reports whether an architectures value is the "all" wildcard
+func IsAllArchitectures(arch string) bool {
+	return strings.EqualFold(strings.TrimSpace(arch), AllArchitectures)
 }
 
 // DigestResult represents a single container digest result
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// DefaultRegistry is the registry of images named without one, like in docker pull
+const DefaultRegistry = "docker.io"
+
+// legacyDefaultRegistry is the old hostname of Docker Hub, normalized to DefaultRegistry
+const legacyDefaultRegistry = "index.docker.io"
+
+// officialPrefix is the namespace of Docker Hub's official images, added to single-component names
+const officialPrefix = "library/"
+
+var (
+	// imageNamePattern matches a repository path: lowercase components separated by slashes
+	imageNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	// imageTagPattern matches a tag
+	imageTagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// Image is a full image reference split into the fields of a container
+type Image struct {
+	Repository string // Registry hostname (e.g., docker.io)
+	Name       string // Repository path (e.g., library/busybox)
+	Tag        string // Tag, empty if none was given
+	Digest     string // Digest, empty if none was given
+}
+
+// ParseImage parses an image reference such as "busybox:1.36", "ghcr.io/home-assistant/home-assistant:stable"
+// or "busybox:1.36@sha256:...", normalized like Docker does: the first component is only a registry if it
+// contains a dot or colon or is localhost, images without one are on Docker Hub, and single-component
+// Docker Hub names are official images under library/.
+func ParseImage(s string) (Image, error) {
+	var image Image
+	rest := s
+
+	if name, dgst, found := strings.Cut(rest, "@"); found {
+		if _, err := digest.Parse(dgst); err != nil {
+			return Image{}, fmt.Errorf("invalid image %q: bad digest: %w", s, err)
+		}
+		rest, image.Digest = name, dgst
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, image.Tag = rest[:i], rest[i+1:]
+		if !imageTagPattern.MatchString(image.Tag) {
+			return Image{}, fmt.Errorf("invalid image %q: bad tag %q", s, image.Tag)
+		}
+	}
+
+	image.Repository, image.Name = DefaultRegistry, rest
+	if first, remainder, found := strings.Cut(rest, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first) {
+		image.Repository, image.Name = first, remainder
+	}
+	if image.Repository == legacyDefaultRegistry {
+		image.Repository = DefaultRegistry
+	}
+	if image.Repository == DefaultRegistry && !strings.Contains(image.Name, "/") {
+		image.Name = officialPrefix + image.Name
+	}
+
+	if !imageNamePattern.MatchString(image.Name) {
+		return Image{}, fmt.Errorf("invalid image %q: repository names must be lowercase letters, digits and separators", s)
+	}
+	return image, nil
+}
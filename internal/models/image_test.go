@@ -0,0 +1,43 @@
+package models
+
+import "testing"
+
+func TestParseImage(t *testing.T) {
+	tests := map[string]Image{
+		"busybox":                                      {Repository: "docker.io", Name: "library/busybox"},
+		"busybox:1.36":                                 {Repository: "docker.io", Name: "library/busybox", Tag: "1.36"},
+		"grafana/grafana:11.1.0":                       {Repository: "docker.io", Name: "grafana/grafana", Tag: "11.1.0"},
+		"docker.io/busybox":                            {Repository: "docker.io", Name: "library/busybox"},
+		"index.docker.io/library/busybox:latest":       {Repository: "docker.io", Name: "library/busybox", Tag: "latest"},
+		"ghcr.io/home-assistant/home-assistant:stable": {Repository: "ghcr.io", Name: "home-assistant/home-assistant", Tag: "stable"},
+		"localhost/app":                                {Repository: "localhost", Name: "app"},
+		"registry.local:5000/team/app:2.0":             {Repository: "registry.local:5000", Name: "team/app", Tag: "2.0"},
+		"busybox:1.36@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74": {
+			Repository: "docker.io", Name: "library/busybox", Tag: "1.36",
+			Digest: "sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74",
+		},
+		"busybox@sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74": {
+			Repository: "docker.io", Name: "library/busybox",
+			Digest: "sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74",
+		},
+	}
+
+	for input, expected := range tests {
+		image, err := ParseImage(input)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", input, err)
+			continue
+		}
+		if image != expected {
+			t.Errorf("Expected %s to parse as %+v, got %+v", input, expected, image)
+		}
+	}
+}
+
+func TestParseImageInvalid(t *testing.T) {
+	for _, input := range []string{"", "BusyBox", "busybox:", "busybox:-1", "busybox@sha256:abc", "ghcr.io/", "busybox//app"} {
+		if _, err := ParseImage(input); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Lock records what each repository:tag resolved to on a previous run, so later
+// runs can detect unchanged tags without downloading their manifests again
+type Lock struct {
+	Entries map[string]LockEntry `json:"entries"` // Entries keyed by LockKey
+}
+
+// LockEntry is the recorded resolution of a single repository:tag
+type LockEntry struct {
+	Digest       string  `json:"digest"`                  // Digest of the manifest the tag pointed to
+	Platforms    ArchMap `json:"platforms"`               // Digest for each architecture resolved from that manifest
+	AllPlatforms bool    `json:"all_platforms,omitempty"` // Whether Platforms holds every platform in the manifest
+	VersionTag   string  `json:"version_tag,omitempty"`   // Most specific tag that pointed to the same digest
+
+	MediaTypes map[string]string `json:"media_types,omitempty"` // Media type of the manifest and of each platform's manifest, keyed by digest
+}
+
+// LockKey returns the key used for a repository:tag in a Lock (e.g., docker.io/library/busybox:latest)
+func LockKey(registry, name, tag string) string {
+	return fmt.Sprintf("%s/%s:%s", registry, name, tag)
+}
+
+// NewLock creates a lock recording the given results. Results for the same
+// repository:tag, from entries asking for different platforms, are merged into
+// one entry when they resolved to the same digest.
+func NewLock(results TagResults) *Lock {
+	lock := &Lock{Entries: map[string]LockEntry{}}
+	for _, result := range results {
+		key := LockKey(result.Repository, result.Name, result.LockTag())
+		entry := LockEntry{
+			Digest:       result.Digest,
+			Platforms:    result.Platforms,
+			AllPlatforms: result.AllPlatforms,
+			VersionTag:   result.VersionTag,
+			MediaTypes:   result.MediaTypes,
+		}
+		if previous, exists := lock.Entries[key]; exists && previous.Digest == entry.Digest {
+			entry = previous.merge(entry)
+		}
+		lock.Entries[key] = entry
+	}
+	return lock
+}
+
+// merge combines two entries for the same digest, keeping the platforms and media types of both
+func (e LockEntry) merge(other LockEntry) LockEntry {
+	merged := LockEntry{
+		Digest:       e.Digest,
+		Platforms:    ArchMap{},
+		AllPlatforms: e.AllPlatforms || other.AllPlatforms,
+		VersionTag:   e.VersionTag,
+		MediaTypes:   map[string]string{},
+	}
+	if merged.VersionTag == "" {
+		merged.VersionTag = other.VersionTag
+	}
+	for _, entry := range []LockEntry{e, other} {
+		maps.Copy(merged.Platforms, entry.Platforms)
+		maps.Copy(merged.MediaTypes, entry.MediaTypes)
+	}
+	return merged
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"maps"
+	"time"
+)
+
 // NestedDigestResults represents the nested structure for container digests
 // Format:
 //
@@ -22,3 +27,121 @@ type TagMap map[string]ArchMap
 
 // ArchMap maps architectures to their digests
 type ArchMap map[string]string
+
+// TagResult holds everything resolved for a single repository:tag
+type TagResult struct {
+	Repository   string  // Repository hostname (e.g., docker.io, ghcr.io)
+	Name         string  // Container name (e.g., library/busybox)
+	Tag          string  // Container tag (e.g., latest), used as the output key
+	ResolvedTag  string  // Concrete tag picked by a tag constraint, empty for literal tags
+	VersionTag   string  // Most specific tag pointing to the same digest, if looked up
+	Digest       string  // Digest of the manifest the tag points to (the index for multi-arch images)
+	Platforms    ArchMap // Digest for each requested architecture
+	AllPlatforms bool    // Whether Platforms holds every platform in the image rather than a selection
+
+	MediaTypes map[string]string // Media type of the manifest and of each platform's manifest, keyed by digest
+
+	Referrers map[string][]Referrer    // Artifacts referring to the index or a platform, keyed by its digest, if looked up
+	Metadata  map[string]ImageMetadata // Details from each platform's image config, keyed like Platforms, if looked up
+	Sizes     map[string]int64         // Compressed size of each platform's layers in bytes, keyed like Platforms, if looked up
+}
+
+// ImageMetadata holds the details of a platform's image read from its config
+type ImageMetadata struct {
+	Created      *time.Time        `json:"created,omitempty"`       // When the image was built, if recorded
+	ConfigDigest string            `json:"config_digest,omitempty"` // Digest of the image config, which is the image ID
+	Labels       map[string]string `json:"labels,omitempty"`        // OCI labels (org.opencontainers.image.*) such as the version, revision and source
+}
+
+// MetadataResults holds the metadata of each platform, nested by registry, repository, tag and platform
+type MetadataResults map[string]map[string]map[string]map[string]ImageMetadata
+
+// TagResults is a slice of TagResult, in the order of the containers config
+type TagResults []*TagResult
+
+// DefaultIndexKey is the key the index digest is recorded under when enabled
+const DefaultIndexKey = "_index"
+
+// TagKey is the key the concrete tag is recorded under for tags picked by a constraint
+const TagKey = "_tag"
+
+// VersionKey is the key the version tag sharing a tag's digest is recorded under
+const VersionKey = "_version"
+
+// MetadataKey is the key the metadata of a tag's platforms is recorded under in the Nix output
+const MetadataKey = "_metadata"
+
+// IsTagKey reports whether a key in an ArchMap holds a tag rather than a digest
+func IsTagKey(key string) bool {
+	return key == TagKey || key == VersionKey
+}
+
+// LockTag returns the tag the digests were resolved from, which is the
+// concrete tag when it was picked by a constraint
+func (r *TagResult) LockTag() string {
+	if r.ResolvedTag != "" {
+		return r.ResolvedTag
+	}
+	return r.Tag
+}
+
+// Metadata arranges the metadata of every result that has any by registry, repository, tag and platform
+func (r TagResults) Metadata() MetadataResults {
+	results := MetadataResults{}
+	for _, result := range r {
+		if len(result.Metadata) == 0 {
+			continue
+		}
+		if _, exists := results[result.Repository]; !exists {
+			results[result.Repository] = map[string]map[string]map[string]ImageMetadata{}
+		}
+		if _, exists := results[result.Repository][result.Name]; !exists {
+			results[result.Repository][result.Name] = map[string]map[string]ImageMetadata{}
+		}
+		// Entries listing the same tag with different architectures share its metadata, like in Nested
+		if _, exists := results[result.Repository][result.Name][result.Tag]; !exists {
+			results[result.Repository][result.Name][result.Tag] = map[string]ImageMetadata{}
+		}
+		maps.Copy(results[result.Repository][result.Name][result.Tag], result.Metadata)
+	}
+	return results
+}
+
+// Nested arranges the results into the nested registry/repository/tag/architecture structure.
+// If indexKey is not empty, the digest of the manifest each tag points to, when known,
+// is recorded under that key next to the architectures. Tags picked by a constraint also record
+// the concrete tag under TagKey, and tags looked up by version record it under VersionKey.
+func (r TagResults) Nested(indexKey string) NestedDigestResults {
+	results := NestedDigestResults{}
+
+	for _, result := range r {
+		// Initialize maps if they don't exist
+		if _, exists := results[result.Repository]; !exists {
+			results[result.Repository] = RepositoryMap{}
+		}
+
+		if _, exists := results[result.Repository][result.Name]; !exists {
+			results[result.Repository][result.Name] = TagMap{}
+		}
+
+		if _, exists := results[result.Repository][result.Name][result.Tag]; !exists {
+			results[result.Repository][result.Name][result.Tag] = ArchMap{}
+		}
+
+		// Add the digests to the nested structure
+		for arch, digest := range result.Platforms {
+			results[result.Repository][result.Name][result.Tag][arch] = digest
+		}
+		if indexKey != "" && result.Digest != "" {
+			results[result.Repository][result.Name][result.Tag][indexKey] = result.Digest
+		}
+		if result.ResolvedTag != "" {
+			results[result.Repository][result.Name][result.Tag][TagKey] = result.ResolvedTag
+		}
+		if result.VersionTag != "" {
+			results[result.Repository][result.Name][result.Tag][VersionKey] = result.VersionTag
+		}
+	}
+
+	return results
+}
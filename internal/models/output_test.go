@@ -0,0 +1,130 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testTagResults returns two results for the same repository
+func testTagResults() TagResults {
+	return TagResults{
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "latest",
+			Digest:     "sha256:aaaa",
+			Platforms:  ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "1.36",
+			Digest:     "sha256:cccc",
+			Platforms:  ArchMap{"linux/arm64": "sha256:dddd"},
+		},
+	}
+}
+
+func TestNested(t *testing.T) {
+	expected := NestedDigestResults{
+		"docker.io": RepositoryMap{
+			"library/busybox": TagMap{
+				"latest": ArchMap{"linux/amd64": "sha256:bbbb"},
+				"1.36":   ArchMap{"linux/arm64": "sha256:dddd"},
+			},
+		},
+	}
+
+	if results := testTagResults().Nested(""); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestNestedWithIndex(t *testing.T) {
+	results := testTagResults().Nested(DefaultIndexKey)
+
+	tags := results["docker.io"]["library/busybox"]
+	if tags["latest"]["_index"] != "sha256:aaaa" {
+		t.Errorf("Expected index digest sha256:aaaa for latest, got %q", tags["latest"]["_index"])
+	}
+	if tags["1.36"]["_index"] != "sha256:cccc" {
+		t.Errorf("Expected index digest sha256:cccc for 1.36, got %q", tags["1.36"]["_index"])
+	}
+	if tags["latest"]["linux/amd64"] != "sha256:bbbb" {
+		t.Errorf("Expected platform digests to be kept next to the index")
+	}
+}
+
+func TestNestedWithResolvedTag(t *testing.T) {
+	results := TagResults{
+		{
+			Repository:  "docker.io",
+			Name:        "library/postgres",
+			Tag:         "16.4-alpine",
+			ResolvedTag: "16.4-alpine",
+			Digest:      "sha256:aaaa",
+			Platforms:   ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+	}
+
+	tags := results.Nested("")["docker.io"]["library/postgres"]
+	if tags["16.4-alpine"][TagKey] != "16.4-alpine" {
+		t.Errorf("Expected resolved tag 16.4-alpine, got %q", tags["16.4-alpine"][TagKey])
+	}
+
+	lock := NewLock(results)
+	if _, exists := lock.Entries["docker.io/library/postgres:16.4-alpine"]; !exists {
+		t.Errorf("Expected lock entry keyed by the resolved tag, got %v", lock.Entries)
+	}
+}
+
+func TestNestedWithVersionTag(t *testing.T) {
+	results := testTagResults()
+	results[0].VersionTag = "1.37.0"
+
+	tags := results.Nested("")["docker.io"]["library/busybox"]
+	if tags["latest"][VersionKey] != "1.37.0" {
+		t.Errorf("Expected version 1.37.0 for latest, got %q", tags["latest"][VersionKey])
+	}
+	if _, exists := tags["1.36"][VersionKey]; exists {
+		t.Errorf("Expected no version for 1.36")
+	}
+
+	if entry := NewLock(results).Entries["docker.io/library/busybox:latest"]; entry.VersionTag != "1.37.0" {
+		t.Errorf("Expected the lock to record version 1.37.0, got %q", entry.VersionTag)
+	}
+}
+
+func TestNewLockMergesPlatforms(t *testing.T) {
+	results := TagResults{
+		{Repository: "docker.io", Name: "library/busybox", Tag: "1.36", Digest: "sha256:aaaa", Platforms: ArchMap{"linux/amd64": "sha256:bbbb"}},
+		{Repository: "docker.io", Name: "library/busybox", Tag: "1.36", Digest: "sha256:aaaa", Platforms: ArchMap{"linux/arm64": "sha256:cccc"}, VersionTag: "1.36.1"},
+	}
+
+	entry := NewLock(results).Entries["docker.io/library/busybox:1.36"]
+	expected := ArchMap{"linux/amd64": "sha256:bbbb", "linux/arm64": "sha256:cccc"}
+	if !reflect.DeepEqual(entry.Platforms, expected) {
+		t.Errorf("Expected platforms %v, got %v", expected, entry.Platforms)
+	}
+	if entry.VersionTag != "1.36.1" {
+		t.Errorf("Expected version 1.36.1, got %q", entry.VersionTag)
+	}
+	if len(results[0].Platforms) != 1 {
+		t.Errorf("Expected the results to be left unchanged, got %v", results[0].Platforms)
+	}
+}
+
+func TestMetadataMergesEntriesForTheSameTag(t *testing.T) {
+	results := TagResults{
+		{Repository: "docker.io", Name: "library/busybox", Tag: "1.36", Metadata: map[string]ImageMetadata{"linux/amd64": {ConfigDigest: "sha256:aaaa"}}},
+		{Repository: "docker.io", Name: "library/busybox", Tag: "1.36", Metadata: map[string]ImageMetadata{"linux/arm64": {ConfigDigest: "sha256:bbbb"}}},
+	}
+
+	expected := map[string]ImageMetadata{"linux/amd64": {ConfigDigest: "sha256:aaaa"}, "linux/arm64": {ConfigDigest: "sha256:bbbb"}}
+	if metadata := results.Metadata()["docker.io"]["library/busybox"]["1.36"]; !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v, got %v", expected, metadata)
+	}
+	if len(results[0].Metadata) != 1 {
+		t.Errorf("Expected the results to be left unchanged, got %v", results[0].Metadata)
+	}
+}
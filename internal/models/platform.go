@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Platform identifies the operating system and CPU an image is built for
+type Platform struct {
+	OS           string // Operating system (e.g., linux, windows)
+	OSVersion    string // Operating system version, only used by Windows images (e.g., 10.0.17763.1234)
+	Architecture string // CPU architecture (e.g., amd64, arm64)
+	Variant      string // CPU variant (e.g., v7 for arm, v3 for amd64)
+}
+
+// platformPartPattern matches a single component of a platform string
+var platformPartPattern = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+
+// osVersionPattern matches an OS with a version in parentheses (e.g., "windows(10.0.17763)")
+var osVersionPattern = regexp.MustCompile(`^([a-z0-9_-]+)\(([A-Za-z0-9_.-]+)\)$`)
+
+// architectureAliases maps architecture names used outside of OCI to their
+// OCI architecture and, where the alias implies one, variant
+var architectureAliases = map[string]Platform{
+	"x86_64":  {Architecture: "amd64"},
+	"x86-64":  {Architecture: "amd64"},
+	"x64":     {Architecture: "amd64"},
+	"aarch64": {Architecture: "arm64"},
+	"armv8":   {Architecture: "arm64"},
+	"armhf":   {Architecture: "arm", Variant: "v7"},
+	"armv7":   {Architecture: "arm", Variant: "v7"},
+	"armv7l":  {Architecture: "arm", Variant: "v7"},
+	"armel":   {Architecture: "arm", Variant: "v6"},
+	"armv6":   {Architecture: "arm", Variant: "v6"},
+	"armv6l":  {Architecture: "arm", Variant: "v6"},
+	"armv5":   {Architecture: "arm", Variant: "v5"},
+	"i386":    {Architecture: "386"},
+	"i686":    {Architecture: "386"},
+	"x86":     {Architecture: "386"},
+	"ppc64el": {Architecture: "ppc64le"},
+}
+
+// ParsePlatform parses a platform string such as "linux/arm64", "linux/arm/v7"
+// or "windows(10.0.17763.1234)/amd64" and normalizes it, so aliases like
+// "linux/aarch64" and "linux/arm64/v8" both become "linux/arm64".
+// A lone architecture (e.g., "amd64") is treated as a linux platform.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "/")
+	if len(parts) > 3 {
+		return Platform{}, fmt.Errorf("invalid platform %q: expected os/architecture[/variant]", s)
+	}
+
+	// A single component is an architecture on linux
+	if len(parts) == 1 {
+		parts = []string{"linux", parts[0]}
+	}
+
+	plat := Platform{}
+
+	// Split an OS version given in parentheses off the OS
+	if match := osVersionPattern.FindStringSubmatch(parts[0]); match != nil {
+		plat.OS = match[1]
+		plat.OSVersion = match[2]
+	} else {
+		plat.OS = parts[0]
+	}
+	plat.Architecture = parts[1]
+	if len(parts) == 3 {
+		plat.Variant = parts[2]
+	}
+
+	for _, part := range []string{plat.OS, plat.Architecture} {
+		if !platformPartPattern.MatchString(part) {
+			return Platform{}, fmt.Errorf("invalid platform %q: os and architecture are required", s)
+		}
+	}
+	if plat.Variant != "" && !platformPartPattern.MatchString(plat.Variant) {
+		return Platform{}, fmt.Errorf("invalid platform %q: invalid variant %q", s, plat.Variant)
+	}
+
+	return plat.Normalize(), nil
+}
+
+// Normalize returns the platform with aliases resolved and default variants applied,
+// following the same rules as containerd
+func (p Platform) Normalize() Platform {
+	p.OS = strings.ToLower(p.OS)
+	p.Architecture = strings.ToLower(p.Architecture)
+	p.Variant = strings.ToLower(p.Variant)
+
+	if p.OS == "macos" {
+		p.OS = "darwin"
+	}
+
+	if alias, exists := architectureAliases[p.Architecture]; exists {
+		p.Architecture = alias.Architecture
+		if alias.Variant != "" {
+			p.Variant = alias.Variant
+		}
+	}
+
+	switch p.Architecture {
+	case "amd64":
+		// v1 is the baseline and is omitted
+		if p.Variant == "v1" {
+			p.Variant = ""
+		}
+	case "arm64":
+		// v8 is the baseline and is omitted
+		if p.Variant == "v8" || p.Variant == "8" {
+			p.Variant = ""
+		}
+	case "arm":
+		// Plain arm means v7, and bare version numbers get a "v" prefix
+		switch p.Variant {
+		case "", "7":
+			p.Variant = "v7"
+		case "5", "6", "8":
+			p.Variant = "v" + p.Variant
+		}
+	case "386":
+		p.Variant = ""
+	}
+
+	return p
+}
+
+// String returns the canonical form of the platform, used as its output key
+// (e.g., "linux/arm/v7" or "windows(10.0.17763.1234)/amd64")
+func (p Platform) String() string {
+	s := p.OS
+	if p.OSVersion != "" {
+		s += "(" + p.OSVersion + ")"
+	}
+	s += "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
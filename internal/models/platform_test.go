@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestParsePlatform(t *testing.T) {
+	tests := map[string]string{
+		"linux/amd64":                    "linux/amd64",
+		"amd64":                          "linux/amd64",
+		"linux/x86_64":                   "linux/amd64",
+		"linux/amd64/v1":                 "linux/amd64",
+		"linux/amd64/v3":                 "linux/amd64/v3",
+		"linux/aarch64":                  "linux/arm64",
+		"linux/arm64/v8":                 "linux/arm64",
+		"linux/arm64/v9":                 "linux/arm64/v9",
+		"linux/arm":                      "linux/arm/v7",
+		"linux/armhf":                    "linux/arm/v7",
+		"linux/armel":                    "linux/arm/v6",
+		"linux/arm/6":                    "linux/arm/v6",
+		"linux/arm/v5":                   "linux/arm/v5",
+		"linux/i386":                     "linux/386",
+		"Linux/PPC64EL":                  "linux/ppc64le",
+		"windows(10.0.17763.1234)/amd64": "windows(10.0.17763.1234)/amd64",
+	}
+
+	for input, expected := range tests {
+		plat, err := ParsePlatform(input)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", input, err)
+			continue
+		}
+		if plat.String() != expected {
+			t.Errorf("Expected %s to parse as %s, got %s", input, expected, plat.String())
+		}
+	}
+}
+
+func TestParsePlatformOSVersion(t *testing.T) {
+	plat, err := ParsePlatform("windows(10.0.17763.1234)/amd64")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plat.OS != "windows" || plat.OSVersion != "10.0.17763.1234" || plat.Architecture != "amd64" {
+		t.Errorf("Unexpected platform: %+v", plat)
+	}
+}
+
+func TestParsePlatformInvalid(t *testing.T) {
+	for _, input := range []string{"", "linux/", "/amd64", "linux/arm/v7/extra", "linux/amd 64"} {
+		if _, err := ParsePlatform(input); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import "fmt"
+
+// RichResults is the rich JSON output, nesting registry, repository and tag like
+// NestedDigestResults but describing each tag in detail.
+// Format:
+//
+//	{
+//	  "docker.io": {
+//	    "library/alpine": {
+//	      "3.20": {
+//	        "digest": "sha256:beefdbd8...",
+//	        "reference": "docker.io/library/alpine@sha256:beefdbd8...",
+//	        "platforms": {
+//	          "linux/amd64": {
+//	            "digest": "sha256:dabf91b6...",
+//	            "reference": "docker.io/library/alpine@sha256:dabf91b6..."
+//	          }
+//	        }
+//	      }
+//	    }
+//	  }
+//	}
+type RichResults map[string]map[string]map[string]RichTag
+
+// RichTag describes everything resolved for a tag
+type RichTag struct {
+	Tag       string                  `json:"tag,omitempty"`        // Concrete tag picked by a constraint or pattern
+	Version   string                  `json:"version,omitempty"`    // Most specific tag pointing to the same digest
+	Digest    string                  `json:"digest,omitempty"`     // Digest of the manifest the tag points to
+	Reference string                  `json:"reference,omitempty"`  // Digest reference to that manifest
+	MediaType string                  `json:"media_type,omitempty"` // Media type of that manifest, if known
+	Referrers []Referrer              `json:"referrers,omitempty"`  // Artifacts referring to that manifest
+	Platforms map[string]RichPlatform `json:"platforms"`            // Each requested platform, keyed by its canonical name
+}
+
+// RichPlatform describes a single platform of a tag
+type RichPlatform struct {
+	Digest         string     `json:"digest"`               // Digest of the platform's manifest
+	Reference      string     `json:"reference"`            // Digest reference to that manifest
+	MediaType      string     `json:"media_type,omitempty"` // Media type of that manifest, if known
+	Referrers      []Referrer `json:"referrers,omitempty"`  // Artifacts referring to that manifest
+	Size           int64      `json:"size,omitempty"`       // Compressed size of the platform's layers in bytes, if looked up
+	SizeDelta      *int64     `json:"size_delta,omitempty"` // Change in size since the previous output, if it recorded one
+	*ImageMetadata            // Details from the platform's image config, if looked up
+}
+
+// Referrer is an artifact such as a signature, SBOM or attestation that refers to a manifest
+type Referrer struct {
+	ArtifactType string `json:"artifact_type"` // Type of artifact (e.g., application/spdx+json)
+	MediaType    string `json:"media_type"`    // Media type of the artifact's manifest
+	Digest       string `json:"digest"`        // Digest of the artifact's manifest
+}
+
+// Rich arranges the results into the rich output. References always name the
+// configured registry and repository, like the other outputs.
+func (r TagResults) Rich() RichResults {
+	results := RichResults{}
+
+	for _, result := range r {
+		if _, exists := results[result.Repository]; !exists {
+			results[result.Repository] = map[string]map[string]RichTag{}
+		}
+		if _, exists := results[result.Repository][result.Name]; !exists {
+			results[result.Repository][result.Name] = map[string]RichTag{}
+		}
+
+		// Entries listing the same tag with different architectures share its platforms, like in Nested
+		tag, exists := results[result.Repository][result.Name][result.Tag]
+		if !exists {
+			tag = RichTag{Platforms: map[string]RichPlatform{}}
+		}
+		if result.ResolvedTag != "" {
+			tag.Tag = result.ResolvedTag
+		}
+		if result.VersionTag != "" {
+			tag.Version = result.VersionTag
+		}
+		if result.Digest != "" {
+			tag.Digest = result.Digest
+			tag.Reference = result.DigestReference(result.Digest)
+		}
+		if mediaType := result.MediaTypes[result.Digest]; mediaType != "" {
+			tag.MediaType = mediaType
+		}
+		if referrers, ok := result.Referrers[result.Digest]; ok {
+			tag.Referrers = referrers
+		}
+		for arch, digest := range result.Platforms {
+			platform := RichPlatform{
+				Digest:    digest,
+				Reference: result.DigestReference(digest),
+				MediaType: result.MediaTypes[digest],
+				Referrers: result.Referrers[digest],
+				Size:      result.Sizes[arch],
+			}
+			if metadata, ok := result.Metadata[arch]; ok {
+				platform.ImageMetadata = &metadata
+			}
+			tag.Platforms[arch] = platform
+		}
+		results[result.Repository][result.Name][result.Tag] = tag
+	}
+
+	return results
+}
+
+// CompareSizes records how much the size of each platform changed since a previous output.
+// Platforms are matched by registry, repository, tag and platform, and only compared
+// when both outputs recorded a size.
+func (r RichResults) CompareSizes(previous RichResults) {
+	for registry, repositories := range r {
+		for name, tags := range repositories {
+			for tag, richTag := range tags {
+				previousTag, ok := previous[registry][name][tag]
+				if !ok {
+					continue
+				}
+				for arch, platform := range richTag.Platforms {
+					previousPlatform, ok := previousTag.Platforms[arch]
+					if platform.Size == 0 || !ok || previousPlatform.Size == 0 {
+						continue
+					}
+					delta := platform.Size - previousPlatform.Size
+					platform.SizeDelta = &delta
+					richTag.Platforms[arch] = platform
+				}
+			}
+		}
+	}
+}
+
+// DigestReference returns the reference to a digest in the result's repository (e.g., docker.io/library/busybox@sha256:...)
+func (r *TagResult) DigestReference(digest string) string {
+	return fmt.Sprintf("%s/%s@%s", r.Repository, r.Name, digest)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRich(t *testing.T) {
+	results := TagResults{
+		{
+			Repository:  "docker.io",
+			Name:        "library/postgres",
+			Tag:         "16",
+			ResolvedTag: "16.4",
+			Digest:      "sha256:aaaa",
+			Platforms:   ArchMap{"linux/amd64": "sha256:bbbb"},
+			Referrers: map[string][]Referrer{
+				"sha256:aaaa": {{ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json", Digest: "sha256:cccc"}},
+				"sha256:bbbb": {},
+			},
+		},
+	}
+
+	expected := RichResults{
+		"docker.io": {
+			"library/postgres": {
+				"16": {
+					Tag:       "16.4",
+					Digest:    "sha256:aaaa",
+					Reference: "docker.io/library/postgres@sha256:aaaa",
+					Referrers: []Referrer{{ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json", Digest: "sha256:cccc"}},
+					Platforms: map[string]RichPlatform{
+						"linux/amd64": {
+							Digest:    "sha256:bbbb",
+							Reference: "docker.io/library/postgres@sha256:bbbb",
+							Referrers: []Referrer{},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if rich := results.Rich(); !reflect.DeepEqual(rich, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, rich)
+	}
+}
+
+func TestRichMergesEntriesForTheSameTag(t *testing.T) {
+	results := TagResults{
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "1.36",
+			Digest:     "sha256:aaaa",
+			Platforms:  ArchMap{"linux/amd64": "sha256:bbbb"},
+			Referrers:  map[string][]Referrer{"sha256:aaaa": {}, "sha256:bbbb": {}},
+			Sizes:      map[string]int64{"linux/amd64": 2000},
+		},
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "1.36",
+			Digest:     "sha256:aaaa",
+			Platforms:  ArchMap{"linux/arm64": "sha256:cccc"},
+			Sizes:      map[string]int64{"linux/arm64": 1500},
+		},
+	}
+
+	tag := results.Rich()["docker.io"]["library/busybox"]["1.36"]
+	if len(tag.Platforms) != 2 || tag.Platforms["linux/amd64"].Size != 2000 || tag.Platforms["linux/arm64"].Size != 1500 {
+		t.Errorf("Expected both platforms with their sizes, got %+v", tag.Platforms)
+	}
+	if tag.Referrers == nil || tag.Platforms["linux/amd64"].Referrers == nil {
+		t.Errorf("Expected the referrers of the first entry to be kept, got %+v", tag)
+	}
+}
+
+func TestCompareSizes(t *testing.T) {
+	rich := TagResults{
+		{
+			Repository: "docker.io",
+			Name:       "library/busybox",
+			Tag:        "1.36",
+			Platforms:  ArchMap{"linux/amd64": "sha256:aaaa", "linux/arm64": "sha256:bbbb", "linux/riscv64": "sha256:cccc"},
+			Sizes:      map[string]int64{"linux/amd64": 2000, "linux/arm64": 1500, "linux/riscv64": 1800},
+		},
+	}.Rich()
+
+	previous := RichResults{
+		"docker.io": {
+			"library/busybox": {
+				"1.36": {
+					Platforms: map[string]RichPlatform{
+						"linux/amd64": {Size: 2500},
+						"linux/arm64": {Size: 1500},
+						// Sizes weren't looked up for this platform last time
+						"linux/riscv64": {},
+					},
+				},
+			},
+		},
+	}
+	rich.CompareSizes(previous)
+
+	platforms := rich["docker.io"]["library/busybox"]["1.36"].Platforms
+	if delta := platforms["linux/amd64"].SizeDelta; delta == nil || *delta != -500 {
+		t.Errorf("Expected a delta of -500 for linux/amd64, got %v", delta)
+	}
+	if delta := platforms["linux/arm64"].SizeDelta; delta == nil || *delta != 0 {
+		t.Errorf("Expected a delta of 0 for linux/arm64, got %v", delta)
+	}
+	if delta := platforms["linux/riscv64"].SizeDelta; delta != nil {
+		t.Errorf("Expected no delta without a previous size, got %d", *delta)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of local image sources
+const (
+	SourceOCIDir        = "ocidir"         // OCI image layout directory (e.g., ocidir://build/app:1.0)
+	SourceOCIArchive    = "oci-archive"    // Tarball of an OCI image layout (e.g., oci-archive:app.tar)
+	SourceDockerArchive = "docker-archive" // Tarball written by docker save (e.g., docker-archive:app.tar:app:1.0)
+)
+
+// Source is a local image a container is read from instead of its registry
+type Source struct {
+	Kind string // SourceOCIDir, SourceOCIArchive or SourceDockerArchive
+	Path string // Directory or tarball the image is in
+	Name string // Tag (or repo:tag in docker archives) selecting the image, empty to use the container's tag
+}
+
+// ParseSource parses a source such as "ocidir://build/app:1.0", "oci-archive:app.tar"
+// or "docker-archive:app.tar:app:1.0". Like regclient, an OCI layout's tag follows
+// the last colon of the path, while like skopeo, an archive's path ends at its first colon.
+func ParseSource(s string) (Source, error) {
+	var source Source
+	switch {
+	case strings.HasPrefix(s, SourceOCIDir+"://"):
+		source.Kind = SourceOCIDir
+		source.Path = strings.TrimPrefix(s, SourceOCIDir+"://")
+		if i := strings.LastIndex(source.Path, ":"); i > strings.LastIndex(source.Path, "/") {
+			source.Path, source.Name = source.Path[:i], source.Path[i+1:]
+		}
+	case strings.HasPrefix(s, SourceOCIArchive+":"), strings.HasPrefix(s, SourceDockerArchive+":"):
+		var rest string
+		source.Kind, rest, _ = strings.Cut(s, ":")
+		source.Path, source.Name, _ = strings.Cut(rest, ":")
+	default:
+		return Source{}, fmt.Errorf("invalid source %q: must start with %s://, %s: or %s:",
+			s, SourceOCIDir, SourceOCIArchive, SourceDockerArchive)
+	}
+
+	if source.Path == "" {
+		return Source{}, fmt.Errorf("invalid source %q: missing path", s)
+	}
+	return source, nil
+}
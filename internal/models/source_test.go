@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestParseSource(t *testing.T) {
+	tests := map[string]Source{
+		"ocidir://build/app":                     {Kind: SourceOCIDir, Path: "build/app"},
+		"ocidir://build/app:1.0":                 {Kind: SourceOCIDir, Path: "build/app", Name: "1.0"},
+		"ocidir:///srv/images/app.v2/layout":     {Kind: SourceOCIDir, Path: "/srv/images/app.v2/layout"},
+		"oci-archive:app.tar":                    {Kind: SourceOCIArchive, Path: "app.tar"},
+		"oci-archive:out/app.tar:1.0":            {Kind: SourceOCIArchive, Path: "out/app.tar", Name: "1.0"},
+		"docker-archive:app.tar:example/app:1.0": {Kind: SourceDockerArchive, Path: "app.tar", Name: "example/app:1.0"},
+		"docker-archive:/tmp/images/app.tar.gz":  {Kind: SourceDockerArchive, Path: "/tmp/images/app.tar.gz"},
+	}
+
+	for input, expected := range tests {
+		source, err := ParseSource(input)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", input, err)
+			continue
+		}
+		if source != expected {
+			t.Errorf("Expected %s to parse as %+v, got %+v", input, expected, source)
+		}
+	}
+}
+
+func TestParseSourceInvalid(t *testing.T) {
+	for _, input := range []string{"", "build/app", "ocidir://", "oci-archive:", "docker://busybox"} {
+		if _, err := ParseSource(input); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/descriptor"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// cachedManifest returns a manifest from the on-disk cache if it can be trusted.
+// Manifests requested by digest never change, while a tag is only trusted for the
+// cache TTL, or for as long as it is cached when offline. A fresh tag entry is enough
+// to answer a HEAD request, but a GET also needs the manifest body.
+// Local images are never cached, since reading them is as cheap as reading the cache.
+func (c *Client) cachedManifest(r ref.Ref, head bool) (manifest.Manifest, bool) {
+	if c.opts.Cache == nil || isLocal(r) {
+		return nil, false
+	}
+
+	desc := descriptor.Descriptor{}
+	if r.Digest != "" {
+		desc.Digest = digest.Digest(r.Digest)
+	} else {
+		entry, ok := c.opts.Cache.GetTag(r.CommonName())
+		if !ok || !(c.opts.Offline || c.opts.Cache.Fresh(entry.Fetched)) {
+			return nil, false
+		}
+		desc.Digest = digest.Digest(entry.Digest)
+		desc.MediaType = entry.MediaType
+	}
+
+	mediaType, raw, ok := c.opts.Cache.GetBlob(desc.Digest.String())
+	if !ok && (!head || r.Digest != "") {
+		return nil, false
+	}
+
+	opts := []manifest.Opts{manifest.WithRef(r)}
+	if ok {
+		desc.MediaType = mediaType
+		desc.Size = int64(len(raw))
+		opts = append(opts, manifest.WithRaw(raw))
+	}
+	m, err := manifest.New(append(opts, manifest.WithDesc(desc))...)
+	if err != nil {
+		return nil, false
+	}
+
+	c.cacheHits.Add(1)
+	return m, true
+}
+
+// storeManifest records a manifest fetched from a registry in the on-disk cache.
+// Failing to write the cache only costs a request next time, so errors are just logged.
+func (c *Client) storeManifest(r ref.Ref, m manifest.Manifest) {
+	if c.opts.Cache == nil || m == nil || isLocal(r) {
+		return
+	}
+
+	desc := m.GetDescriptor()
+	if r.Digest == "" && r.Tag != "" {
+		if err := c.opts.Cache.PutTag(r.CommonName(), desc.Digest.String(), desc.MediaType); err != nil {
+			c.logf("Failed to cache %s: %v\n", r.CommonName(), err)
+		}
+	}
+
+	// HEAD responses have no body to store
+	if raw, err := m.RawBody(); err == nil && len(raw) > 0 {
+		if err := c.opts.Cache.PutBlob(desc.Digest.String(), desc.MediaType, raw); err != nil {
+			c.logf("Failed to cache manifest %s: %v\n", desc.Digest, err)
+		}
+	}
+}
+
+// cachedTagList returns the tags of a repository from the on-disk cache if the list is
+// still fresh, or whatever was last listed when offline
+func (c *Client) cachedTagList(r ref.Ref) ([]string, bool) {
+	if c.opts.Cache == nil {
+		return nil, false
+	}
+
+	entry, ok := c.opts.Cache.GetTagList(r.CommonName())
+	if !ok || !(c.opts.Offline || c.opts.Cache.Fresh(entry.Fetched)) {
+		return nil, false
+	}
+
+	c.cacheHits.Add(1)
+	return entry.Tags, true
+}
+
+// storeTagList records the tags listed for a repository in the on-disk cache
+func (c *Client) storeTagList(r ref.Ref, tagList []string) {
+	if c.opts.Cache == nil {
+		return
+	}
+
+	if err := c.opts.Cache.PutTagList(r.CommonName(), tagList); err != nil {
+		c.logf("Failed to cache tags of %s: %v\n", r.CommonName(), err)
+	}
+}
+
+// cachedConfig returns an image config from the on-disk cache
+func (c *Client) cachedConfig(desc descriptor.Descriptor) (blob.OCIConfig, bool) {
+	if c.opts.Cache == nil {
+		return nil, false
+	}
+
+	_, raw, ok := c.opts.Cache.GetBlob(desc.Digest.String())
+	if !ok {
+		return nil, false
+	}
+
+	c.cacheHits.Add(1)
+	return blob.NewOCIConfig(blob.WithRawBody(raw), blob.WithDesc(desc)), true
+}
+
+// storeConfig records an image config fetched from a registry in the on-disk cache
+func (c *Client) storeConfig(desc descriptor.Descriptor, imageConfig blob.OCIConfig) {
+	if c.opts.Cache == nil {
+		return
+	}
+
+	raw, err := imageConfig.RawBody()
+	if err != nil {
+		return
+	}
+	if err := c.opts.Cache.PutBlob(desc.Digest.String(), desc.MediaType, raw); err != nil {
+		c.logf("Failed to cache config %s: %v\n", desc.Digest, err)
+	}
+}
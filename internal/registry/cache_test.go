@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/cache"
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestManifestCacheRoundTrip(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Cache = cache.New(filepath.Join(t.TempDir(), "cache"), time.Hour)
+
+	r, err := ref.New("docker.io/library/busybox:latest")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	index := newTestIndex(t)
+	client.storeManifest(r, index)
+
+	// A GET for the tag is answered from the cache without a registry client request
+	m, err := client.manifestGet(context.Background(), r)
+	if err != nil {
+		t.Fatalf("manifestGet returned an error: %v", err)
+	}
+	if m.GetDescriptor().Digest != index.GetDescriptor().Digest || !m.IsList() {
+		t.Errorf("Expected the cached index %s, got %s", index.GetDescriptor().Digest, m.GetDescriptor().Digest)
+	}
+
+	// So is a GET by digest
+	if _, ok := client.cachedManifest(r.SetDigest(index.GetDescriptor().Digest.String()), false); !ok {
+		t.Error("Expected the index to be cached by digest")
+	}
+
+	stats := client.Stats()
+	if stats.ManifestGets != 0 || stats.CacheHits != 2 {
+		t.Errorf("Expected 2 cache hits and no GETs, got %+v", stats)
+	}
+}
+
+func TestManifestCacheDisabled(t *testing.T) {
+	client := NewMockClient()
+
+	r, err := ref.New("docker.io/library/busybox:latest")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	client.storeManifest(r, newTestIndex(t))
+	if _, ok := client.cachedManifest(r, false); ok {
+		t.Error("Expected no cache to be used when it is disabled")
+	}
+}
+
+func TestOfflineResolvesFromCacheAndLock(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Offline = true
+	client.opts.Concurrency = 1
+	// Every entry is already stale, which offline mode ignores
+	client.opts.Cache = cache.New(filepath.Join(t.TempDir(), "cache"), time.Nanosecond)
+	client.opts.Lock = &models.Lock{Entries: map[string]models.LockEntry{
+		"docker.io/library/postgres:16": {
+			Platforms: models.ArchMap{"linux/amd64": "sha256:cccc"},
+		},
+	}}
+
+	r, err := ref.New("docker.io/library/busybox:latest")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+	client.storeManifest(r, newTestIndex(t))
+
+	containers := []models.Container{
+		{Repository: "docker.io", Name: "library/busybox", Tag: "latest", Architectures: []string{"linux/amd64"}},
+		{Repository: "docker.io", Name: "library/postgres", Tag: "16", Architectures: []string{"linux/amd64"}},
+	}
+	results, err := client.ResolveTags(context.Background(), &models.ContainersConfig{Containers: containers})
+	if err != nil {
+		t.Fatalf("ResolveTags returned an error: %v", err)
+	}
+	if digest := results[0].Platforms["linux/amd64"]; digest != "sha256:1111111111111111111111111111111111111111111111111111111111111111" {
+		t.Errorf("Expected the cached amd64 digest for busybox, got %s", digest)
+	}
+	if digest := results[1].Platforms["linux/amd64"]; digest != "sha256:cccc" {
+		t.Errorf("Expected the locked digest for postgres, got %s", digest)
+	}
+
+	// Entries without a cached answer are all reported, not just the first
+	containers = append(containers,
+		models.Container{Repository: "docker.io", Name: "library/alpine", Tag: "3", Architectures: []string{"linux/amd64"}},
+		models.Container{Repository: "ghcr.io", Name: "example/app", TagConstraint: "^1"},
+	)
+	_, err = client.ResolveTags(context.Background(), &models.ContainersConfig{Containers: containers})
+	var unresolved *UnresolvedError
+	if !errors.As(err, &unresolved) || !errors.Is(err, ErrOffline) {
+		t.Fatalf("Expected an offline UnresolvedError, got %v", err)
+	}
+	expected := []string{"docker.io/library/alpine:3", "ghcr.io/example/app (tag \"^1\")"}
+	if !reflect.DeepEqual(unresolved.Unresolved, expected) || unresolved.Total != 4 {
+		t.Errorf("Expected %v of 4 unresolved, got %v of %d", expected, unresolved.Unresolved, unresolved.Total)
+	}
+}
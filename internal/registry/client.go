@@ -2,129 +2,605 @@ package registry
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/fdrake/container-digest/internal/cache"
 	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/mediatype"
+	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 )
 
+// Default concurrency limits used when none are specified
+const (
+	DefaultConcurrency         = 8
+	DefaultRegistryConcurrency = 4
+)
+
+// DefaultRequestTimeout is how long a single registry request may take before it is abandoned and retried
+const DefaultRequestTimeout = 30 * time.Second
+
+// Options configures the behavior of the registry client
+type Options struct {
+	Concurrency         int           // Maximum number of lookups in flight across all registries
+	RegistryConcurrency int           // Maximum number of lookups in flight against a single registry
+	Lock                *models.Lock  // Results of a previous run, used to skip downloading unchanged manifests
+	Retry               RetryPolicy   // How failed registry operations are retried
+	RequestTimeout      time.Duration // Time limit for each attempt of a registry operation, 0 for none
+	QuotaExceeded       string        // Policy when Docker Hub entries exceed the pull quota (QuotaFail, QuotaHeadOnly or QuotaIgnore)
+	Strict              bool          // Fail when a requested platform is missing instead of falling back to the index digest
+	Cache               *cache.Cache  // On-disk cache of manifests and tags, nil to disable it
+	Offline             bool          // Answer only from the lock and the cache, never contacting a registry
+	Referrers           bool          // Look up the signatures, SBOMs and attestations referring to each digest
+	Metadata            bool          // Read the created time, config digest and labels of each platform's image
+	Sizes               bool          // Sum the compressed size of each platform's layers
+	MediaTypes          string        // Media type policy for containers that don't set their own, models.DefaultMediaTypes if empty
+	Log                 io.Writer     // Destination for verbose progress messages, nil to disable them
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Concurrency:         DefaultConcurrency,
+		RegistryConcurrency: DefaultRegistryConcurrency,
+		Retry:               DefaultRetryPolicy(),
+		RequestTimeout:      DefaultRequestTimeout,
+		QuotaExceeded:       QuotaFail,
+		Strict:              true,
+		MediaTypes:          models.DefaultMediaTypes,
+	}
+}
+
+// ErrPlatformNotFound is returned in strict mode when a requested platform is not in an image
+var ErrPlatformNotFound = errors.New("platform not available")
+
+// ErrOffline is returned in offline mode when an answer would need a registry request
+var ErrOffline = errors.New("offline and not cached")
+
+// Stats counts the manifest requests made by the client
+type Stats struct {
+	ManifestGets  int64 // Manifests downloaded with GET requests
+	ManifestHeads int64 // Manifests checked with HEAD requests
+	GetsAvoided   int64 // GET requests skipped because the tag was unchanged since the lock
+	Retries       int64 // Registry operations retried after a transient failure
+	CacheHits     int64 // Manifests and configs served from the on-disk cache
+}
+
 // Client wraps the Docker registry client
 type Client struct {
-	client *regclient.RegClient
+	client     *regclient.RegClient
+	opts       Options
+	retryAfter *retryAfterTransport
+	mirrors    map[string][]string // Mirrors of each upstream registry, tried in order before it
+
+	manifestGets  atomic.Int64
+	manifestHeads atomic.Int64
+	getsAvoided   atomic.Int64
+	retries       atomic.Int64
+	cacheHits     atomic.Int64
+	hubHeadOnly   atomic.Bool
+	logMu         sync.Mutex
+	quotaMu       sync.Mutex
+	quota         Quota
+	archivesMu    sync.Mutex
+	archives      map[string]*archiveImport // Archives unpacked so far, keyed by path, name and tag
 }
 
 // NewClient creates a new registry client
-func NewClient(containersConfig *models.ContainersConfig) (*Client, error) {
-	// Initialize regclient with Docker config
-	rc := regclient.New(regclient.WithDockerCreds(), regclient.WithDockerCerts())
+func NewClient(containersConfig *models.ContainersConfig, opts Options) (*Client, error) {
+	// Registry settings from the config take precedence over the Docker config
+	hosts, err := hostConfigs(containersConfig.Registries)
+	if err != nil {
+		return nil, err
+	}
+	retryAfter := newRetryAfterTransport(hosts)
+
+	// Initialize regclient with Docker config. Retries are handled by the client's
+	// retry policy, so regclient only makes a single attempt per request and its
+	// backoff between requests to a failing host is as short as it allows.
+	rc := regclient.New(
+		regclient.WithDockerCreds(),
+		regclient.WithConfigHost(hosts...),
+		regclient.WithRegOpts(
+			reg.WithRetryLimit(1),
+			reg.WithDelay(time.Nanosecond, time.Nanosecond),
+			reg.WithHTTPClient(&http.Client{Transport: retryAfter}),
+		),
+	)
 
 	client := &Client{
-		client: rc,
+		client:     rc,
+		opts:       opts,
+		retryAfter: retryAfter,
+		mirrors:    containersConfig.Mirrors,
+		archives:   map[string]*archiveImport{},
 	}
 
 	return client, nil
 }
 
+// logf writes a verbose progress message if logging is enabled
+func (c *Client) logf(format string, args ...any) {
+	if c.opts.Log == nil {
+		return
+	}
+
+	c.logMu.Lock()
+	defer c.logMu.Unlock()
+	fmt.Fprintf(c.opts.Log, format, args...)
+}
+
+// Stats returns the manifest request counts so far
+func (c *Client) Stats() Stats {
+	return Stats{
+		ManifestGets:  c.manifestGets.Load(),
+		ManifestHeads: c.manifestHeads.Load(),
+		GetsAvoided:   c.getsAvoided.Load(),
+		Retries:       c.retries.Load(),
+		CacheHits:     c.cacheHits.Load(),
+	}
+}
+
+// UnresolvedError is returned when a run is canceled or times out before every container is resolved,
+// or when an offline run has no cached answer for some of them
+type UnresolvedError struct {
+	Err        error    // Why the run stopped, context.Canceled, context.DeadlineExceeded or ErrOffline
+	Unresolved []string // Containers without a result, as described by Container.Reference
+	Total      int      // Number of containers in the run
+}
+
+// Error lists the containers that were not resolved
+func (e *UnresolvedError) Error() string {
+	return fmt.Sprintf("%v: %d of %d entries unresolved: %s",
+		e.Err, len(e.Unresolved), e.Total, strings.Join(e.Unresolved, ", "))
+}
+
+// Unwrap returns the reason the run stopped
+func (e *UnresolvedError) Unwrap() error {
+	return e.Err
+}
+
 // GetDigests fetches digests for all containers in the config
-func (c *Client) GetDigests(containersConfig *models.ContainersConfig) (models.NestedDigestResults, error) {
-	results := models.NestedDigestResults{}
-	ctx := context.Background()
-
-	for _, container := range containersConfig.Containers {
-		// For each architecture, get the digest
-		for _, arch := range container.Architectures {
-			// Get the digest for this specific architecture
-			digest, err := c.GetDigest(ctx, container.Repository, container.Name, container.Tag, arch)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get digest for %s/%s:%s (%s): %w",
-					container.Repository, container.Name, container.Tag, arch, err)
+func (c *Client) GetDigests(ctx context.Context, containersConfig *models.ContainersConfig) (models.NestedDigestResults, error) {
+	results, err := c.ResolveTags(ctx, containersConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Nested(""), nil
+}
+
+// ResolveTags resolves every container in the config.
+// Lookups run concurrently within the configured limits, but the results and
+// the error returned match what a sequential walk of the config would produce.
+// If ctx is canceled or times out, in-flight requests are abandoned and an
+// UnresolvedError lists the containers that were not resolved. Offline, every
+// container is tried and an UnresolvedError lists those without a cached answer.
+func (c *Client) ResolveTags(ctx context.Context, containersConfig *models.ContainersConfig) (models.TagResults, error) {
+	// Build one job per container, each writing to its own slot
+	containers := containersConfig.Containers
+	results := make(models.TagResults, len(containers))
+	uncached := make([]bool, len(containers))
+	jobs := make([]job, len(containers))
+
+	for i, container := range containers {
+		jobs[i] = job{
+			registry: container.Repository,
+			run: func() error {
+				// Don't start on containers once the run has been canceled
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				// Resolve every architecture of this tag from a single manifest fetch
+				result, err := c.resolveContainer(ctx, container)
+				if errors.Is(err, ErrOffline) {
+					c.logf("No cached answer for %s: %v\n", container.Reference(), err)
+					uncached[i] = true
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("failed to get digests for %s: %w", container.Reference(), err)
+				}
+				results[i] = result
+				return nil
+			},
+		}
+	}
+
+	err := newPool(c.opts.Concurrency, c.opts.RegistryConcurrency).run(jobs)
+	if ctx.Err() != nil {
+		unresolved := []string{}
+		for i, container := range containers {
+			if results[i] == nil {
+				unresolved = append(unresolved, container.Reference())
 			}
+		}
+		return nil, &UnresolvedError{Err: ctx.Err(), Unresolved: unresolved, Total: len(containers)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	unresolved := []string{}
+	for i, container := range containers {
+		if uncached[i] {
+			unresolved = append(unresolved, container.Reference())
+		}
+	}
+	if len(unresolved) > 0 {
+		return nil, &UnresolvedError{Err: ErrOffline, Unresolved: unresolved, Total: len(containers)}
+	}
+
+	return results, nil
+}
+
+// resolveContainer resolves a container, first picking its tag if it is given as a constraint or pattern.
+// The configured tag, if any, stays the output key, and the concrete tag is recorded with the result.
+// Images with verify settings must have a valid cosign signature of the resolved digest.
+// If the container has a version regex, the version tag sharing the resolved digest is looked up too,
+// and so are the referrers of every digest and the metadata of every platform when they are enabled.
+func (c *Client) resolveContainer(ctx context.Context, container models.Container) (*models.TagResult, error) {
+	var tag string
+	var err error
+	switch {
+	case container.TagRegex != "":
+		tag, err = c.resolveRegex(ctx, container)
+	case container.TagConstraint != "":
+		tag, err = c.resolveConstraint(ctx, container)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-			// Initialize maps if they don't exist
-			if _, exists := results[container.Repository]; !exists {
-				results[container.Repository] = models.RepositoryMap{}
+	outputTag := container.Tag
+	if tag != "" {
+		if outputTag == "" {
+			outputTag = tag
+		}
+		container.Tag = tag
+	}
+
+	result, err := c.ResolveTag(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	result.Tag = outputTag
+	if tag != "" {
+		result.ResolvedTag = tag
+	}
+
+	// Refuse images that aren't signed as configured
+	if container.Verify != nil {
+		if err := c.verifySignature(ctx, container, result.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Name the version a floating tag points to, unless the lock already did
+	switch {
+	case container.VersionRegex == "":
+		result.VersionTag = ""
+	case !tags.Matches(result.VersionTag, container.VersionRegex):
+		result.VersionTag, err = c.versionTag(ctx, container, result.Digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.opts.Referrers {
+		if err := c.referrers(ctx, container, result); err != nil {
+			return nil, err
+		}
+	}
+	if c.opts.Metadata || c.opts.Sizes {
+		manifests, err := c.platformManifests(ctx, container, result)
+		if err != nil {
+			return nil, err
+		}
+		if c.opts.Metadata {
+			if err := c.metadata(ctx, container, result, manifests); err != nil {
+				return nil, err
 			}
+		}
+		if c.opts.Sizes {
+			sizes(result, manifests)
+		}
+	}
+	return result, nil
+}
 
-			if _, exists := results[container.Repository][container.Name]; !exists {
-				results[container.Repository][container.Name] = models.TagMap{}
+// ResolveTag fetches the manifest for a container's tag once and picks the
+// digest of every requested architecture from it.
+// When the lock already covers the tag, a HEAD request checks whether the tag
+// still points to the same digest and the manifest is only downloaded if it changed.
+// Offline, the lock is trusted without checking.
+// Containers with a local source are always read from it, under their configured name.
+func (c *Client) ResolveTag(ctx context.Context, container models.Container) (*models.TagResult, error) {
+	imageRef, err := c.imageRef(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	fullRef := imageRef.CommonName()
+
+	// Parse the requested architectures into canonical platforms
+	platforms, err := parsePlatforms(container.Architectures)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TagResult{
+		Repository: container.Repository,
+		Name:       container.Name,
+		Tag:        container.Tag,
+		Platforms:  models.ArchMap{},
+	}
+
+	// Reuse the locked digests if the tag has not moved since the last run
+	if locked, ok := c.lockedEntry(container, platforms); ok {
+		var head manifest.Manifest
+		if !c.opts.Offline {
+			head, err = c.manifestHead(ctx, imageRef)
+		}
+		if c.opts.Offline || (err == nil && head.GetDescriptor().Digest.String() == locked.Digest) {
+			c.getsAvoided.Add(1)
+			result.Digest = locked.Digest
+			result.AllPlatforms = container.AllPlatforms()
+			result.VersionTag = locked.VersionTag
+			for _, plat := range platforms {
+				result.Platforms[plat.String()] = locked.Platforms[plat.String()]
+			}
+			if container.AllPlatforms() {
+				for key, digest := range locked.Platforms {
+					result.Platforms[key] = digest
+				}
 			}
 
-			if _, exists := results[container.Repository][container.Name][container.Tag]; !exists {
-				results[container.Repository][container.Name][container.Tag] = models.ArchMap{}
+			// The HEAD request tells the current media type of the manifest, which older locks don't record
+			mediaTypes := maps.Clone(locked.MediaTypes)
+			if head != nil {
+				if mediaTypes == nil {
+					mediaTypes = map[string]string{}
+				}
+				mediaTypes[locked.Digest] = mediatype.Base(head.GetDescriptor().MediaType)
+			}
+			if err := c.checkMediaTypes(container, result, mediaTypes); err != nil {
+				return nil, err
 			}
+			return result, nil
+		}
+	}
+
+	// Without a matching lock entry the manifest has to be downloaded, which HEAD-only mode forbids
+	if c.headOnly(container.Repository) && container.Source == "" {
+		return nil, fmt.Errorf("%w: %s is not locked or has changed since the lock, and HEAD-only mode prevents downloading its manifest",
+			ErrQuotaExceeded, fullRef)
+	}
 
-			// Add the digest to the nested structure
-			results[container.Repository][container.Name][container.Tag][arch] = digest
+	// Get the general manifest, which is the index for multi-arch images
+	m, err := c.manifestGet(ctx, imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest for %s: %w", fullRef, err)
+	}
+	result.Digest = m.GetDescriptor().Digest.String()
+	result.AllPlatforms = container.AllPlatforms()
+
+	// A single-platform image can only satisfy the platform it was built for
+	if !m.IsList() && (container.AllPlatforms() || c.strict(container)) {
+		imagePlatform, known, err := c.imagePlatform(ctx, imageRef, m)
+		if err != nil {
+			return nil, err
+		}
+		if container.AllPlatforms() {
+			if !known {
+				return nil, fmt.Errorf("%w: %s is a single-platform image whose config doesn't name its platform, so \"all\" can't list it; set its architecture instead",
+					ErrPlatformNotFound, container.Reference())
+			}
+			result.Platforms[imagePlatform.String()] = result.Digest
+		}
+		if known && c.strict(container) {
+			for _, plat := range platforms {
+				if !platform.Match(toRegPlatform(plat), toRegPlatform(imagePlatform)) {
+					return nil, fmt.Errorf("%w: %s; the image only provides %s", ErrPlatformNotFound, plat, imagePlatform)
+				}
+			}
 		}
 	}
 
-	return results, nil
+	// Expand "all" to every platform the index provides
+	if m.IsList() && container.AllPlatforms() {
+		for key, digest := range indexPlatforms(m) {
+			result.Platforms[key] = digest
+		}
+	}
+
+	// Pick each requested platform from the same manifest, keyed by its canonical name
+	for _, plat := range platforms {
+		digest, err := platformDigest(m, plat)
+		if err != nil {
+			if c.strict(container) {
+				return nil, err
+			}
+			c.logf("Using index digest for %s (%s): %v\n", fullRef, plat, err)
+			digest = result.Digest
+		}
+		result.Platforms[plat.String()] = digest
+	}
+
+	if err := c.checkMediaTypes(container, result, manifestMediaTypes(m)); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// GetDigest fetches the digest for a specific container and architecture
-func (c *Client) GetDigest(ctx context.Context, registry, name, tag, architecture string) (string, error) {
-	// Create the full reference string (registry/repository:tag)
-	fullRef := fmt.Sprintf("%s/%s:%s", registry, name, tag)
+// strict reports whether missing platforms are an error for a container
+func (c *Client) strict(container models.Container) bool {
+	return c.opts.Strict && !container.AllowFallback
+}
 
-	// Create image reference
-	imageRef, err := ref.New(fullRef)
+// imagePlatform reads the platform a single-platform image was built for from its config.
+// It reports false if the manifest has no config to read it from (e.g., Docker schema1)
+// or the config doesn't name an OS and architecture.
+func (c *Client) imagePlatform(ctx context.Context, r ref.Ref, m manifest.Manifest) (models.Platform, bool, error) {
+	imageConfig, known, err := c.imageConfig(ctx, r, m)
+	if err != nil || !known || imageConfig.OS == "" || imageConfig.Architecture == "" {
+		return models.Platform{}, false, err
+	}
+
+	return fromRegPlatform(imageConfig.Platform), true, nil
+}
+
+// imageConfig downloads the config of a single-platform image.
+// It reports false if the manifest has no config (e.g., Docker schema1).
+func (c *Client) imageConfig(ctx context.Context, r ref.Ref, m manifest.Manifest) (v1.Image, bool, error) {
+	imager, ok := m.(manifest.Imager)
+	if !ok {
+		return v1.Image{}, false, nil
+	}
+	configDesc, err := imager.GetConfig()
 	if err != nil {
-		return "", fmt.Errorf("failed to create image reference for %s: %w", fullRef, err)
+		return v1.Image{}, false, nil
 	}
 
-	// Parse the architecture string (e.g., "linux/amd64" -> OS: "linux", Architecture: "amd64")
-	parts := strings.Split(architecture, "/")
+	if imageConfig, ok := c.cachedConfig(configDesc); ok {
+		return imageConfig.GetConfig(), true, nil
+	}
 
-	plat := platform.Platform{
-		OS:           "linux", // Default to linux
-		Architecture: "",
+	var imageConfig blob.OCIConfig
+	err = c.mirrored(ctx, r, "GET config", func(ctx context.Context, r ref.Ref) error {
+		var err error
+		imageConfig, err = c.client.BlobGetOCIConfig(ctx, r, configDesc)
+		return err
+	})
+	if err != nil {
+		return v1.Image{}, false, fmt.Errorf("failed to get image config for %s: %w", r.CommonName(), err)
 	}
+	c.storeConfig(configDesc, imageConfig)
 
-	if len(parts) >= 2 {
-		plat.OS = parts[0]
-		plat.Architecture = parts[1]
+	return imageConfig.GetConfig(), true, nil
+}
 
-		// Handle arm variants (e.g., "linux/arm/v7")
-		if len(parts) >= 3 && parts[1] == "arm" {
-			plat.Variant = parts[2]
+// manifestGet downloads a manifest from the registry or its mirrors, retrying transient failures.
+// Cached manifests are used when the cache can vouch for them, and a tag whose cache entry
+// has expired is checked with a HEAD request first in case its manifest is still cached.
+// In HEAD-only mode, only cached manifests are returned and ErrQuotaExceeded otherwise.
+func (c *Client) manifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	if m, ok := c.cachedManifest(r, false); ok {
+		return m, nil
+	}
+	if c.opts.Cache != nil && r.Digest == "" {
+		if _, known := c.opts.Cache.GetTag(r.CommonName()); known {
+			head, err := c.manifestHead(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := c.cachedManifest(r.SetDigest(head.GetDescriptor().Digest.String()), false); ok {
+				return m, nil
+			}
 		}
-	} else {
-		// If the format is not as expected, use the whole string as architecture
-		plat.Architecture = architecture
 	}
 
-	// If architecture is still empty, default to amd64
-	if plat.Architecture == "" {
-		plat.Architecture = "amd64"
+	if c.headOnly(r.Registry) && !isLocal(r) {
+		return nil, fmt.Errorf("%w: HEAD-only mode prevents downloading the manifest of %s", ErrQuotaExceeded, r.CommonName())
 	}
 
-	// First get the general manifest
-	manifest, err := c.client.ManifestGet(ctx, imageRef)
-	if err != nil {
-		return "", fmt.Errorf("failed to get manifest for %s: %w", fullRef, err)
+	var m manifest.Manifest
+	err := c.mirrored(ctx, r, "GET", func(ctx context.Context, r ref.Ref) error {
+		c.manifestGets.Add(1)
+		var err error
+		m, err = c.client.ManifestGet(ctx, r)
+		return err
+	})
+	c.recordQuota(r, m)
+	if err == nil {
+		c.storeManifest(r, m)
+	}
+	return m, err
+}
+
+// manifestHead checks a manifest with a HEAD request to the registry or its mirrors, retrying transient failures.
+// A fresh cache entry for the tag answers the request without contacting the registry.
+func (c *Client) manifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	if m, ok := c.cachedManifest(r, true); ok {
+		return m, nil
 	}
 
-	// If this is a manifest list (multi-arch), try to find the specific platform
-	if manifest.IsList() {
-		// Get the platform-specific descriptor
-		platDesc, err := manifest.GetPlatformDesc(&plat)
-		if err == nil && platDesc != nil {
-			// We found a platform-specific manifest, return its digest
-			return platDesc.Digest.String(), nil
+	var m manifest.Manifest
+	err := c.mirrored(ctx, r, "HEAD", func(ctx context.Context, r ref.Ref) error {
+		c.manifestHeads.Add(1)
+		var err error
+		m, err = c.client.ManifestHead(ctx, r)
+		return err
+	})
+	c.recordQuota(r, m)
+	if err == nil {
+		c.storeManifest(r, m)
+	}
+	return m, err
+}
+
+// lockedEntry returns the lock entry for a container if it covers every requested platform
+func (c *Client) lockedEntry(container models.Container, platforms []models.Platform) (models.LockEntry, bool) {
+	if c.opts.Lock == nil || container.Source != "" {
+		return models.LockEntry{}, false
+	}
+
+	entry, exists := c.opts.Lock.Entries[models.LockKey(container.Repository, container.Name, container.Tag)]
+	// Without a digest the entry can't be checked against the registry, so it is only trusted offline
+	if !exists || (entry.Digest == "" && !c.opts.Offline) {
+		return models.LockEntry{}, false
+	}
+
+	// An entry resolved for a subset of platforms can't stand in for all of them
+	if container.AllPlatforms() && !entry.AllPlatforms {
+		return models.LockEntry{}, false
+	}
+
+	for _, plat := range platforms {
+		if entry.Platforms[plat.String()] == "" {
+			return models.LockEntry{}, false
 		}
 	}
 
-	// Return the digest from the manifest (either single arch or couldn't find platform-specific)
-	return manifest.GetDescriptor().Digest.String(), nil
+	return entry, true
 }
 
-// DebugManifest prints detailed information about a container manifest
-func (c *Client) DebugManifest(registry, name, tag string) error {
-	ctx := context.Background()
+// GetDigest fetches the digest for a specific container and architecture
+func (c *Client) GetDigest(ctx context.Context, registry, name, tag, architecture string) (string, error) {
+	plat, err := models.ParsePlatform(architecture)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.ResolveTag(ctx, models.Container{
+		Repository:    registry,
+		Name:          name,
+		Tag:           tag,
+		Architectures: []string{architecture},
+	})
+	if err != nil {
+		return "", err
+	}
 
+	return result.Platforms[plat.String()], nil
+}
+
+// DebugManifest prints detailed information about a container manifest
+func (c *Client) DebugManifest(ctx context.Context, registry, name, tag string) error {
 	// Create the full reference string (registry/repository:tag)
 	fullRef := fmt.Sprintf("%s/%s:%s", registry, name, tag)
 
@@ -135,7 +611,7 @@ func (c *Client) DebugManifest(registry, name, tag string) error {
 	}
 
 	// Get manifest
-	manifest, err := c.client.ManifestGet(ctx, imageRef)
+	manifest, err := c.manifestGet(ctx, imageRef)
 	if err != nil {
 		return fmt.Errorf("failed to get manifest for %s: %w", fullRef, err)
 	}
@@ -151,11 +627,7 @@ func (c *Client) DebugManifest(registry, name, tag string) error {
 		if err == nil && len(platformList) > 0 {
 			fmt.Println("Available Platforms:")
 			for _, plat := range platformList {
-				fmt.Printf("  - %s/%s", plat.OS, plat.Architecture)
-				if plat.Variant != "" {
-					fmt.Printf("/%s", plat.Variant)
-				}
-				fmt.Println()
+				fmt.Printf("  - %s\n", fromRegPlatform(*plat))
 			}
 		}
 	}
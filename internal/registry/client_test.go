@@ -1,16 +1,24 @@
 package registry
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 
+	"github.com/fdrake/container-digest/internal/models"
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/manifest"
 )
 
 // NewMockClient creates a mock client for testing
 func NewMockClient() *Client {
 	// We don't use real registry clients in tests
 	return &Client{
-		client: regclient.New(),
+		client:     regclient.New(),
+		opts:       DefaultOptions(),
+		retryAfter: newRetryAfterTransport(nil),
+		archives:   map[string]*archiveImport{},
 	}
 }
 
@@ -29,3 +37,133 @@ func TestMockClient(t *testing.T) {
 
 	// Verify the mock client was created successfully
 }
+
+// testIndex is an OCI index with three platforms used to test platform selection
+const testIndex = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+      "size": 100,
+      "platform": {"os": "linux", "architecture": "amd64"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+      "size": 100,
+      "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+      "size": 100,
+      "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}
+    }
+  ]
+}`
+
+// newTestIndex parses testIndex into a manifest
+func newTestIndex(t *testing.T) manifest.Manifest {
+	t.Helper()
+	m, err := manifest.New(manifest.WithRaw([]byte(testIndex)))
+	if err != nil {
+		t.Fatalf("Failed to parse test index: %v", err)
+	}
+	return m
+}
+
+func TestLockedEntry(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Lock = &models.Lock{Entries: map[string]models.LockEntry{
+		"docker.io/library/busybox:latest": {
+			Digest:    "sha256:aaaa",
+			Platforms: models.ArchMap{"linux/amd64": "sha256:bbbb"},
+		},
+	}}
+
+	container := models.Container{
+		Repository:    "docker.io",
+		Name:          "library/busybox",
+		Tag:           "latest",
+		Architectures: []string{"linux/amd64"},
+	}
+	platforms := []models.Platform{{OS: "linux", Architecture: "amd64"}}
+	if _, ok := client.lockedEntry(container, platforms); !ok {
+		t.Error("Expected lock entry covering linux/amd64 to be used")
+	}
+
+	// A newly requested architecture needs the manifest to be fetched again
+	platforms = append(platforms, models.Platform{OS: "linux", Architecture: "arm64"})
+	if _, ok := client.lockedEntry(container, platforms); ok {
+		t.Error("Expected lock entry missing linux/arm64 to be ignored")
+	}
+
+	// A selection of platforms can't stand in for all of them
+	container.Architectures = []string{"all"}
+	if _, ok := client.lockedEntry(container, nil); ok {
+		t.Error("Expected lock entry without all platforms to be ignored for \"all\"")
+	}
+}
+
+func TestLockedSubsetDoesNotStandInForAll(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+	ctx := context.Background()
+	container := models.Container{Repository: host, Name: "example/app", Tag: "1.0", Architectures: []string{"all"}}
+
+	// A run for every platform, then one for linux/amd64 reusing its lock entry
+	all, err := client.resolveContainer(ctx, container)
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+	client.opts.Lock = models.NewLock(models.TagResults{all})
+	container.Architectures = []string{"linux/amd64"}
+	subset, err := client.resolveContainer(ctx, container)
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+	client.opts.Lock = models.NewLock(models.TagResults{subset})
+	if entry := client.opts.Lock.Entries[models.LockKey(host, "example/app", "1.0")]; entry.AllPlatforms {
+		t.Errorf("Expected the lock of linux/amd64 not to cover all platforms, got %+v", entry)
+	}
+
+	// Back to every platform, the lock only has linux/amd64 so the index is read again
+	container.Architectures = []string{"all"}
+	result, err := client.resolveContainer(ctx, container)
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Platforms, all.Platforms) {
+		t.Errorf("Expected platforms %v, got %v", all.Platforms, result.Platforms)
+	}
+}
+
+func TestResolveTagsReportsUnresolved(t *testing.T) {
+	client := NewMockClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.ResolveTags(ctx, &models.ContainersConfig{
+		Containers: []models.Container{
+			{Repository: "docker.io", Name: "library/busybox", Tag: "latest"},
+			{Repository: "ghcr.io", Name: "user/repo", TagConstraint: ">=1"},
+		},
+	})
+
+	var unresolved *UnresolvedError
+	if !errors.As(err, &unresolved) {
+		t.Fatalf("Expected an UnresolvedError, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the error to wrap context.Canceled")
+	}
+
+	expected := []string{"docker.io/library/busybox:latest", `ghcr.io/user/repo (tag ">=1")`}
+	if !reflect.DeepEqual(unresolved.Unresolved, expected) || unresolved.Total != 2 {
+		t.Errorf("Expected unresolved %v of 2, got %v of %d", expected, unresolved.Unresolved, unresolved.Total)
+	}
+}
@@ -0,0 +1,161 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+)
+
+// hostConfigs converts the registries section of the config into regclient host settings,
+// reading every configured secret and certificate. Errors name the registry and where the
+// secret was to be read from, but never include the secret or the output of a secret command.
+func hostConfigs(registries map[string]models.RegistryConfig) ([]config.Host, error) {
+	names := make([]string, 0, len(registries))
+	for name := range registries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hosts := make([]config.Host, 0, len(names))
+	for _, name := range names {
+		registry := registries[name]
+		host := config.HostNewName(name)
+		host.User = registry.Username
+
+		var err error
+		if host.Pass, err = readSecret(registry.Password); err != nil {
+			return nil, fmt.Errorf("failed to read password for %s: %w", name, err)
+		}
+		if host.Token, err = readSecret(registry.Token); err != nil {
+			return nil, fmt.Errorf("failed to read token for %s: %w", name, err)
+		}
+
+		if err := host.TLS.UnmarshalText([]byte(registry.TLS)); err != nil {
+			return nil, fmt.Errorf("invalid tls for %s: %w", name, err)
+		}
+		if host.RegCert, err = readPEM(registry.CAFile); err != nil {
+			return nil, fmt.Errorf("failed to read ca_file for %s: %w", name, err)
+		}
+		if host.ClientCert, err = readPEM(registry.ClientCert); err != nil {
+			return nil, fmt.Errorf("failed to read client_cert for %s: %w", name, err)
+		}
+		if host.ClientKey, err = readPEM(registry.ClientKey); err != nil {
+			return nil, fmt.Errorf("failed to read client_key for %s: %w", name, err)
+		}
+
+		hosts = append(hosts, *host)
+	}
+
+	return hosts, nil
+}
+
+// readPEM reads a PEM file, returning an empty string if no path is given.
+// The contents are checked for a PEM block but never included in errors, since the file may hold a private key.
+func readPEM(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if block, _ := pem.Decode(data); block == nil {
+		return "", fmt.Errorf("no PEM data found in %s", path)
+	}
+	return string(data), nil
+}
+
+// readSecret reads a secret from its source, returning an empty string if there is no source
+func readSecret(source *models.SecretSource) (string, error) {
+	if source == nil {
+		return "", nil
+	}
+
+	switch {
+	case source.Env != "":
+		secret, exists := os.LookupEnv(source.Env)
+		if !exists || secret == "" {
+			return "", fmt.Errorf("environment variable %s is not set", source.Env)
+		}
+		return secret, nil
+	case source.File != "":
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", source.File, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case len(source.Command) > 0:
+		// Output is captured and never shown, since a failing command may still print part of a secret
+		var stdout bytes.Buffer
+		cmd := exec.Command(source.Command[0], source.Command[1:]...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return "", fmt.Errorf("command %s exited with status %d", source.Command[0], exitErr.ExitCode())
+			}
+			return "", fmt.Errorf("failed to run command %s: %w", source.Command[0], err)
+		}
+		return strings.TrimRight(stdout.String(), "\r\n"), nil
+	}
+
+	return "", fmt.Errorf("no env, file or command given")
+}
+
+// hostTLSConfig builds the TLS settings of a host like regclient does: the system certificates,
+// those under the Docker certs directory for the host and its ca_file, with its client certificate
+func hostTLSConfig(host config.Host) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if host.TLS == config.TLSInsecure {
+		tlsConfig.InsecureSkipVerify = true
+	} else {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system certificates: %w", err)
+		}
+		dir := filepath.Join(regclient.DockerCertDir, host.Hostname)
+		files, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".crt") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, file.Name()), err)
+			}
+			if !roots.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in %s", filepath.Join(dir, file.Name()))
+			}
+		}
+		if host.RegCert != "" && !roots.AppendCertsFromPEM([]byte(host.RegCert)) {
+			return nil, fmt.Errorf("no certificates found in the ca_file of %s", host.Name)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if host.ClientCert != "" && host.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(host.ClientCert), []byte(host.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate for %s: %w", host.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/config"
+)
+
+func TestReadSecret(t *testing.T) {
+	t.Setenv("CONTAINER_DIGEST_TEST_SECRET", "from-env")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to create secret file: %v", err)
+	}
+
+	tests := []struct {
+		source   *models.SecretSource
+		expected string
+	}{
+		{nil, ""},
+		{&models.SecretSource{Env: "CONTAINER_DIGEST_TEST_SECRET"}, "from-env"},
+		{&models.SecretSource{File: secretFile}, "from-file"},
+		{&models.SecretSource{Command: []string{"echo", "from-command"}}, "from-command"},
+	}
+
+	for _, test := range tests {
+		secret, err := readSecret(test.source)
+		if err != nil {
+			t.Errorf("readSecret(%+v) returned an error: %v", test.source, err)
+			continue
+		}
+		if secret != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, secret)
+		}
+	}
+}
+
+func TestReadSecretMissingEnv(t *testing.T) {
+	_, err := readSecret(&models.SecretSource{Env: "CONTAINER_DIGEST_TEST_UNSET"})
+	if err == nil || !strings.Contains(err.Error(), "CONTAINER_DIGEST_TEST_UNSET") {
+		t.Errorf("Expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestReadSecretCommandFailureHidesOutput(t *testing.T) {
+	_, err := readSecret(&models.SecretSource{Command: []string{"sh", "-c", "echo hunter2; echo hunter2 >&2; exit 3"}})
+	if err == nil {
+		t.Fatal("Expected an error for a failing command")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Expected the error not to include the command output, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "status 3") {
+		t.Errorf("Expected the error to include the exit status, got %v", err)
+	}
+}
+
+func TestHostConfigs(t *testing.T) {
+	t.Setenv("CONTAINER_DIGEST_TEST_SECRET", "s3cret")
+
+	hosts, err := hostConfigs(map[string]models.RegistryConfig{
+		"ghcr.io": {Username: "user", Password: &models.SecretSource{Env: "CONTAINER_DIGEST_TEST_SECRET"}},
+	})
+	if err != nil {
+		t.Fatalf("hostConfigs returned an error: %v", err)
+	}
+
+	if len(hosts) != 1 || hosts[0].Name != "ghcr.io" || hosts[0].User != "user" || hosts[0].Pass != "s3cret" {
+		t.Errorf("Expected ghcr.io host for user, got %+v", hosts)
+	}
+}
+
+func TestHostConfigsTLS(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	if err := os.WriteFile(caFile, []byte(caPEM), 0644); err != nil {
+		t.Fatalf("Failed to create CA file: %v", err)
+	}
+
+	hosts, err := hostConfigs(map[string]models.RegistryConfig{
+		"lab.example.com":   {TLS: "insecure", CAFile: caFile},
+		"192.168.1.10:5000": {TLS: "disabled"},
+	})
+	if err != nil {
+		t.Fatalf("hostConfigs returned an error: %v", err)
+	}
+
+	// Hosts are returned sorted by name
+	if hosts[0].Name != "192.168.1.10:5000" || hosts[0].TLS != config.TLSDisabled {
+		t.Errorf("Expected plain HTTP for 192.168.1.10:5000, got %+v", hosts[0])
+	}
+	if hosts[1].Name != "lab.example.com" || hosts[1].TLS != config.TLSInsecure || hosts[1].RegCert != caPEM {
+		t.Errorf("Expected insecure TLS with the CA for lab.example.com, got %+v", hosts[1])
+	}
+}
+
+func TestReadPEMHidesContents(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "client.key")
+	if err := os.WriteFile(keyFile, []byte("hunter2"), 0600); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+
+	_, err := readPEM(keyFile)
+	if err == nil {
+		t.Fatal("Expected an error for a file without PEM data")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Expected the error not to include the file contents, got %v", err)
+	}
+}
+
+func TestHostTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	certificate := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		host  config.Host
+		valid bool
+	}{
+		{config.Host{Name: serverURL.Host, Hostname: serverURL.Host}, false},
+		{config.Host{Name: serverURL.Host, Hostname: serverURL.Host, RegCert: certificate}, true},
+		{config.Host{Name: serverURL.Host, Hostname: serverURL.Host, TLS: config.TLSInsecure}, true},
+	}
+	for _, test := range tests {
+		client := &http.Client{Transport: newRetryAfterTransport([]config.Host{test.host})}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if test.valid && err != nil {
+			t.Errorf("Expected a trusted connection with %+v, got %v", test.host, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Expected the server's certificate to be rejected with %+v", test.host)
+		}
+	}
+}
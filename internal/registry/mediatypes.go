@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/mediatype"
+)
+
+// ErrMediaTypeNotAllowed is returned when an image's manifest is of a kind the media type policy rejects
+var ErrMediaTypeNotAllowed = errors.New("media type not allowed")
+
+// allowedMediaTypes lists the manifest media types each policy accepts. MediaTypesAny accepts every media type.
+var allowedMediaTypes = map[string][]string{
+	models.MediaTypesOCI:      {mediatype.OCI1Manifest, mediatype.OCI1ManifestList},
+	models.MediaTypesDockerV2: {mediatype.OCI1Manifest, mediatype.OCI1ManifestList, mediatype.Docker2Manifest, mediatype.Docker2ManifestList},
+}
+
+// mediaTypeNames describes the manifest media types in errors
+var mediaTypeNames = map[string]string{
+	mediatype.OCI1Manifest:          "an OCI manifest",
+	mediatype.OCI1ManifestList:      "an OCI index",
+	mediatype.Docker2Manifest:       "a Docker v2 manifest",
+	mediatype.Docker2ManifestList:   "a Docker v2 manifest list",
+	mediatype.Docker1Manifest:       "a legacy Docker schema1 manifest",
+	mediatype.Docker1ManifestSigned: "a legacy Docker schema1 manifest",
+}
+
+// mediaTypePolicy returns the media type policy of a container, which defaults to the client's
+func (c *Client) mediaTypePolicy(container models.Container) string {
+	switch {
+	case container.MediaTypes != "":
+		return container.MediaTypes
+	case c.opts.MediaTypes != "":
+		return c.opts.MediaTypes
+	}
+	return models.DefaultMediaTypes
+}
+
+// manifestMediaTypes returns the media type of a manifest and, for an index, of each manifest it lists, keyed by digest
+func manifestMediaTypes(m manifest.Manifest) map[string]string {
+	mediaTypes := map[string]string{m.GetDescriptor().Digest.String(): mediatype.Base(m.GetDescriptor().MediaType)}
+
+	indexer, ok := m.(manifest.Indexer)
+	if !ok {
+		return mediaTypes
+	}
+	descriptors, err := indexer.GetManifestList()
+	if err != nil {
+		return mediaTypes
+	}
+	for _, desc := range descriptors {
+		mediaTypes[desc.Digest.String()] = mediatype.Base(desc.MediaType)
+	}
+	return mediaTypes
+}
+
+// checkMediaTypes keeps the media types of a result's digests out of all the ones known
+// and makes sure the container's policy accepts each of them. Digests whose media type
+// isn't known, such as those reused from a lock written without media types, are accepted.
+func (c *Client) checkMediaTypes(container models.Container, result *models.TagResult, known map[string]string) error {
+	policy := c.mediaTypePolicy(container)
+
+	result.MediaTypes = map[string]string{}
+	if mediaType := known[result.Digest]; mediaType != "" {
+		result.MediaTypes[result.Digest] = mediaType
+	}
+	for _, digest := range result.Platforms {
+		if mediaType := known[digest]; mediaType != "" {
+			result.MediaTypes[digest] = mediaType
+		}
+	}
+
+	// Digests are checked in order so the same one is reported every run
+	for _, digest := range slices.Sorted(maps.Keys(result.MediaTypes)) {
+		mediaType := result.MediaTypes[digest]
+		if policy == models.MediaTypesAny || slices.Contains(allowedMediaTypes[policy], mediaType) {
+			continue
+		}
+		name, ok := mediaTypeNames[mediaType]
+		if !ok {
+			name = "an unknown kind of manifest"
+		}
+		return fmt.Errorf("%w: %s@%s is %s (%s), which the %q media type policy rejects; %s",
+			ErrMediaTypeNotAllowed, container.Repository+"/"+container.Name, digest, name, mediaType, policy, mediaTypeHint(mediaType))
+	}
+	return nil
+}
+
+// mediaTypeHint suggests what to do about a manifest a policy rejects
+func mediaTypeHint(mediaType string) string {
+	if slices.Contains(allowedMediaTypes[models.MediaTypesDockerV2], mediaType) {
+		return fmt.Sprintf("set media_types = %q on the container to accept Docker v2 images", models.MediaTypesDockerV2)
+	}
+	if mediaType == mediatype.Docker1Manifest || mediaType == mediatype.Docker1ManifestSigned {
+		return fmt.Sprintf("push the image again with a current builder, or set media_types = %q on the container if your runtime still pulls schema1 images", models.MediaTypesAny)
+	}
+	return fmt.Sprintf("set media_types = %q on the container to accept it", models.MediaTypesAny)
+}
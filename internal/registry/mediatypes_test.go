@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/mediatype"
+)
+
+func TestCheckMediaTypes(t *testing.T) {
+	client := NewMockClient()
+	known := map[string]string{
+		"sha256:aaaa": mediatype.Docker2ManifestList,
+		"sha256:bbbb": mediatype.Docker2Manifest,
+		"sha256:cccc": mediatype.Docker1ManifestSigned,
+	}
+
+	tests := []struct {
+		policy    string
+		platforms models.ArchMap
+		allowed   bool
+	}{
+		{models.MediaTypesDockerV2, models.ArchMap{"linux/amd64": "sha256:bbbb"}, true},
+		{models.MediaTypesOCI, models.ArchMap{"linux/amd64": "sha256:bbbb"}, false},
+		{models.MediaTypesDockerV2, models.ArchMap{"linux/amd64": "sha256:cccc"}, false},
+		{models.MediaTypesAny, models.ArchMap{"linux/amd64": "sha256:cccc"}, true},
+		// The default policy rejects schema1
+		{"", models.ArchMap{"linux/amd64": "sha256:cccc"}, false},
+	}
+
+	for _, tt := range tests {
+		container := models.Container{Repository: "docker.io", Name: "library/busybox", MediaTypes: tt.policy}
+		result := &models.TagResult{Digest: "sha256:aaaa", Platforms: tt.platforms}
+		err := client.checkMediaTypes(container, result, known)
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %q to allow %v, got %v", tt.policy, tt.platforms, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrMediaTypeNotAllowed) {
+			t.Errorf("Expected %q to reject %v, got %v", tt.policy, tt.platforms, err)
+		}
+		// Only the result's own digests are recorded
+		if len(result.MediaTypes) != 2 {
+			t.Errorf("Expected the media types of 2 digests, got %v", result.MediaTypes)
+		}
+	}
+}
+
+func TestCheckMediaTypesNamesSchema1(t *testing.T) {
+	client := NewMockClient()
+	container := models.Container{Repository: "docker.io", Name: "library/busybox"}
+	result := &models.TagResult{Digest: "sha256:aaaa", Platforms: models.ArchMap{}}
+
+	err := client.checkMediaTypes(container, result, map[string]string{"sha256:aaaa": mediatype.Docker1Manifest})
+	if err == nil || !strings.Contains(err.Error(), "docker.io/library/busybox@sha256:aaaa is a legacy Docker schema1 manifest") {
+		t.Errorf("Expected an error naming the schema1 manifest, got %v", err)
+	}
+}
+
+func TestCheckMediaTypesAcceptsUnknown(t *testing.T) {
+	client := NewMockClient()
+	container := models.Container{Repository: "docker.io", Name: "library/busybox", MediaTypes: models.MediaTypesOCI}
+	result := &models.TagResult{Digest: "sha256:aaaa", Platforms: models.ArchMap{"linux/amd64": "sha256:bbbb"}}
+
+	// Digests reused from an older lock have no media type to check
+	if err := client.checkMediaTypes(container, result, nil); err != nil {
+		t.Errorf("Expected digests without a media type to be accepted, got %v", err)
+	}
+}
+
+func TestResolveTagRecordsMediaTypes(t *testing.T) {
+	client := NewMockClient()
+	layout := writeTestLayout(t)
+
+	result, err := client.ResolveTag(context.Background(), models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/arm64"},
+		Source:        "ocidir://" + layout.dir,
+		MediaTypes:    models.MediaTypesOCI,
+	})
+	if err != nil {
+		t.Fatalf("ResolveTag returned an error: %v", err)
+	}
+
+	expected := map[string]string{
+		layout.index:                    mediatype.OCI1ManifestList,
+		layout.platforms["linux/arm64"]: mediatype.OCI1Manifest,
+	}
+	if len(result.MediaTypes) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result.MediaTypes)
+	}
+	for digest, mediaType := range expected {
+		if result.MediaTypes[digest] != mediaType {
+			t.Errorf("Expected media type %s for %s, got %s", mediaType, digest, result.MediaTypes[digest])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/manifest"
+)
+
+// ociLabelPrefix is the namespace of the labels recorded in the metadata
+const ociLabelPrefix = "org.opencontainers.image."
+
+// metadata reads the created time, config digest and OCI labels of each platform of a result
+// from the image config of its manifest, fetched by platformManifests. Configs are cached by
+// digest like manifests, so later runs read them from the cache.
+func (c *Client) metadata(ctx context.Context, container models.Container, result *models.TagResult, manifests map[string]manifest.Manifest) error {
+	r, err := c.imageRef(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	result.Metadata = map[string]models.ImageMetadata{}
+	for key, m := range manifests {
+		configDesc, err := m.(manifest.Imager).GetConfig()
+		if err != nil {
+			continue
+		}
+
+		imageConfig, known, err := c.imageConfig(ctx, r.SetDigest(result.Platforms[key]), m)
+		if err != nil {
+			return err
+		}
+		metadata := models.ImageMetadata{ConfigDigest: configDesc.Digest.String()}
+		if known {
+			metadata.Created = imageConfig.Created
+			for label, value := range imageConfig.Config.Labels {
+				if strings.HasPrefix(label, ociLabelPrefix) {
+					if metadata.Labels == nil {
+						metadata.Labels = map[string]string{}
+					}
+					metadata.Labels[label] = value
+				}
+			}
+		}
+		result.Metadata[key] = metadata
+	}
+	return nil
+}
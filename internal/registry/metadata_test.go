@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+func TestResolveMetadata(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Metadata = true
+	layout := writeTestLayout(t)
+
+	result, err := client.resolveContainer(context.Background(), models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64", "linux/arm64"},
+		Source:        "ocidir://" + layout.dir,
+	})
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, key := range []string{"linux/amd64", "linux/arm64"} {
+		metadata, ok := result.Metadata[key]
+		if !ok {
+			t.Fatalf("Expected metadata for %s", key)
+		}
+		if metadata.ConfigDigest != layout.configs[key] {
+			t.Errorf("Expected config digest %s for %s, got %s", layout.configs[key], key, metadata.ConfigDigest)
+		}
+		if metadata.Created == nil || !metadata.Created.Equal(created) {
+			t.Errorf("Expected created time %s for %s, got %v", created, key, metadata.Created)
+		}
+		// Only OCI labels are kept
+		if expected := map[string]string{"org.opencontainers.image.version": "1.0"}; !reflect.DeepEqual(metadata.Labels, expected) {
+			t.Errorf("Expected labels %v for %s, got %v", expected, key, metadata.Labels)
+		}
+	}
+}
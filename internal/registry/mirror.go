@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/regclient/regclient/types/ref"
+)
+
+// hosts returns the hosts to query for a registry: its mirrors in the configured order, then the registry itself
+func (c *Client) hosts(registry string) []string {
+	return append(append([]string{}, c.mirrors[registry]...), registry)
+}
+
+// mirrored runs a registry operation against each mirror of the reference's registry
+// in order, falling back to the next mirror and finally the registry itself when one fails.
+// Each host is retried with the retry policy before moving on to the next one.
+// The reference passed to op points to the host being tried.
+// Offline, no host is contacted and ErrOffline is returned.
+// Local images are read directly, without mirrors or retries, even offline.
+func (c *Client) mirrored(ctx context.Context, r ref.Ref, verb string, op func(ctx context.Context, r ref.Ref) error) error {
+	if isLocal(r) {
+		return op(ctx, r)
+	}
+	if c.opts.Offline {
+		return fmt.Errorf("%w: %s %s", ErrOffline, verb, r.CommonName())
+	}
+
+	var err error
+	for _, host := range c.hosts(r.Registry) {
+		hostRef := r
+		hostRef.Registry = host
+		hostRef.Reference = hostRef.CommonName()
+
+		err = c.retry(ctx, host, verb+" "+hostRef.CommonName(), func(ctx context.Context) error {
+			return op(ctx, hostRef)
+		})
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if host != r.Registry {
+			c.logf("Mirror %s failed for %s, trying the next host: %v\n", host, r.CommonName(), err)
+		}
+	}
+	return err
+}
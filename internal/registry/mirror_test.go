@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/regclient/regclient/types/errs"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestMirroredFallbackOrder(t *testing.T) {
+	client := NewMockClient()
+	client.mirrors = map[string][]string{"docker.io": {"mirror-a.internal", "mirror-b.internal:5000"}}
+
+	r, err := ref.New("docker.io/library/busybox:latest")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	// Fail on the first mirror so the second one is used
+	var tried []string
+	err = client.mirrored(context.Background(), r, "GET", func(_ context.Context, r ref.Ref) error {
+		tried = append(tried, r.CommonName())
+		if r.Registry == "mirror-a.internal" {
+			return errs.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mirrored returned an error: %v", err)
+	}
+
+	expected := []string{"mirror-a.internal/library/busybox:latest", "mirror-b.internal:5000/library/busybox:latest"}
+	if !reflect.DeepEqual(tried, expected) {
+		t.Errorf("Expected hosts %v to be tried, got %v", expected, tried)
+	}
+}
+
+func TestMirroredFallsBackToUpstream(t *testing.T) {
+	client := NewMockClient()
+	client.mirrors = map[string][]string{"docker.io": {"mirror.internal"}}
+
+	r, err := ref.New("docker.io/library/busybox:latest")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	var tried []string
+	err = client.mirrored(context.Background(), r, "GET", func(_ context.Context, r ref.Ref) error {
+		tried = append(tried, r.Registry)
+		return errs.ErrNotFound
+	})
+	if err == nil {
+		t.Error("Expected an error when every host fails")
+	}
+
+	expected := []string{"mirror.internal", "docker.io"}
+	if !reflect.DeepEqual(tried, expected) {
+		t.Errorf("Expected hosts %v to be tried, got %v", expected, tried)
+	}
+}
+
+func TestHostsWithoutMirrors(t *testing.T) {
+	client := NewMockClient()
+	if hosts := client.hosts("ghcr.io"); !reflect.DeepEqual(hosts, []string{"ghcr.io"}) {
+		t.Errorf("Expected only ghcr.io, got %v", hosts)
+	}
+}
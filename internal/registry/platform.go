@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+)
+
+// attestationReferenceType is the annotation BuildKit sets on attestation manifests in an index
+const attestationReferenceType = "vnd.docker.reference.type"
+
+// parsePlatforms parses the architectures of a container into canonical platforms,
+// dropping aliases of a platform that was already requested and the "all" wildcard
+func parsePlatforms(architectures []string) ([]models.Platform, error) {
+	seen := map[string]bool{}
+	var platforms []models.Platform
+
+	for _, arch := range architectures {
+		if models.IsAllArchitectures(arch) {
+			continue
+		}
+		plat, err := models.ParsePlatform(arch)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[plat.String()] {
+			seen[plat.String()] = true
+			platforms = append(platforms, plat)
+		}
+	}
+
+	return platforms, nil
+}
+
+// toRegPlatform converts a platform to the regclient type used for matching
+func toRegPlatform(plat models.Platform) platform.Platform {
+	return platform.Platform{
+		OS:           plat.OS,
+		OSVersion:    plat.OSVersion,
+		Architecture: plat.Architecture,
+		Variant:      plat.Variant,
+	}
+}
+
+// fromRegPlatform converts a regclient platform to a canonical platform
+func fromRegPlatform(plat platform.Platform) models.Platform {
+	return models.Platform{
+		OS:           plat.OS,
+		OSVersion:    plat.OSVersion,
+		Architecture: plat.Architecture,
+		Variant:      plat.Variant,
+	}.Normalize()
+}
+
+// platformDigest returns the digest for a platform from an already fetched manifest.
+// Single-platform manifests return their own digest, while an index that doesn't
+// contain the platform returns an error listing the platforms it does contain.
+func platformDigest(m manifest.Manifest, plat models.Platform) (string, error) {
+	if !m.IsList() {
+		return m.GetDescriptor().Digest.String(), nil
+	}
+
+	// Get the platform-specific descriptor
+	regPlat := toRegPlatform(plat)
+	platDesc, err := manifest.GetPlatformDesc(m, &regPlat)
+	if err == nil && platDesc != nil {
+		return platDesc.Digest.String(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s; available platforms are %s",
+		ErrPlatformNotFound, plat, strings.Join(availablePlatforms(m), ", "))
+}
+
+// indexPlatforms returns the digest of every platform in an index keyed by canonical name.
+// Entries without a real platform, such as BuildKit attestations, are skipped.
+func indexPlatforms(m manifest.Manifest) map[string]string {
+	platforms := map[string]string{}
+
+	indexer, ok := m.(manifest.Indexer)
+	if !ok {
+		return platforms
+	}
+	descriptors, err := indexer.GetManifestList()
+	if err != nil {
+		return platforms
+	}
+
+	for _, desc := range descriptors {
+		if desc.Platform == nil || desc.Platform.OS == "unknown" || desc.Platform.Architecture == "unknown" {
+			continue
+		}
+		if _, isAttestation := desc.Annotations[attestationReferenceType]; isAttestation {
+			continue
+		}
+
+		// Keep the first entry if the index lists a platform twice
+		key := fromRegPlatform(*desc.Platform).String()
+		if _, exists := platforms[key]; !exists {
+			platforms[key] = desc.Digest.String()
+		}
+	}
+
+	return platforms
+}
+
+// availablePlatforms lists the canonical platforms in an index, sorted
+func availablePlatforms(m manifest.Manifest) []string {
+	var names []string
+	for name := range indexPlatforms(m) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// platformManifests fetches the image manifest of each platform of a result once, for the lookups
+// that read platform images, keyed like the result's platforms. Manifests are cached by digest,
+// so only those not read before are downloaded, which HEAD-only mode forbids. Platforms pinned
+// to the index digest, when strict matching is off, have no image of their own and are left out.
+func (c *Client) platformManifests(ctx context.Context, container models.Container, result *models.TagResult) (map[string]manifest.Manifest, error) {
+	r, err := c.imageRef(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := map[string]manifest.Manifest{}
+	byDigest := map[string]manifest.Manifest{}
+	for key, digest := range result.Platforms {
+		m, fetched := byDigest[digest]
+		if !fetched {
+			platformRef := r.SetDigest(digest)
+			m, err = c.manifestGet(ctx, platformRef)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get manifest for %s: %w", platformRef.CommonName(), err)
+			}
+			byDigest[digest] = m
+		}
+		if _, isImage := m.(manifest.Imager); isImage && !m.IsList() {
+			manifests[key] = m
+		}
+	}
+	return manifests, nil
+}
@@ -0,0 +1,253 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types/descriptor"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/mediatype"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// mustParsePlatform parses a platform string or fails the test
+func mustParsePlatform(t *testing.T, s string) models.Platform {
+	t.Helper()
+	plat, err := models.ParsePlatform(s)
+	if err != nil {
+		t.Fatalf("Failed to parse platform %s: %v", s, err)
+	}
+	return plat
+}
+
+func TestPlatformDigest(t *testing.T) {
+	m := newTestIndex(t)
+
+	tests := map[string]string{
+		"linux/amd64":  "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"linux/arm64":  "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		"linux/arm/v7": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+	}
+
+	for arch, expected := range tests {
+		digest, err := platformDigest(m, mustParsePlatform(t, arch))
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", arch, err)
+		}
+		if digest != expected {
+			t.Errorf("Expected digest %s for %s, got %s", expected, arch, digest)
+		}
+	}
+}
+
+func TestPlatformDigestMissingPlatform(t *testing.T) {
+	m := newTestIndex(t)
+
+	_, err := platformDigest(m, mustParsePlatform(t, "linux/amd46"))
+	if !errors.Is(err, ErrPlatformNotFound) {
+		t.Fatalf("Expected ErrPlatformNotFound, got %v", err)
+	}
+
+	expected := "available platforms are linux/amd64, linux/arm/v7, linux/arm64"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error to list available platforms, got %q", err.Error())
+	}
+}
+
+func TestStrict(t *testing.T) {
+	client := NewMockClient()
+
+	if !client.strict(models.Container{}) {
+		t.Error("Expected strict mode to be on by default")
+	}
+	if client.strict(models.Container{AllowFallback: true}) {
+		t.Error("Expected allow_fallback to disable strict mode for the container")
+	}
+
+	client.opts.Strict = false
+	if client.strict(models.Container{}) {
+		t.Error("Expected strict mode to be off when disabled")
+	}
+}
+
+func TestPlatformDigestAliases(t *testing.T) {
+	m := newTestIndex(t)
+
+	// Aliases resolve to the same entries as their canonical names
+	tests := map[string]string{
+		"linux/aarch64":  "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		"linux/arm64/v8": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		"linux/x86_64":   "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"linux/armhf":    "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+	}
+
+	for arch, expected := range tests {
+		digest, err := platformDigest(m, mustParsePlatform(t, arch))
+		if err != nil || digest != expected {
+			t.Errorf("Expected digest %s for %s, got %s (%v)", expected, arch, digest, err)
+		}
+	}
+}
+
+func TestParsePlatformsDeduplicates(t *testing.T) {
+	platforms, err := parsePlatforms([]string{"linux/arm64", "linux/aarch64", "linux/amd64"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(platforms) != 2 {
+		t.Fatalf("Expected aliases to be merged into 2 platforms, got %v", platforms)
+	}
+	if platforms[0].String() != "linux/arm64" || platforms[1].String() != "linux/amd64" {
+		t.Errorf("Unexpected platforms: %v", platforms)
+	}
+}
+
+// testIndexWithAttestations is an OCI index as published by BuildKit, with attestation manifests
+const testIndexWithAttestations = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+      "size": 100,
+      "platform": {"os": "linux", "architecture": "amd64"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+      "size": 100,
+      "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:4444444444444444444444444444444444444444444444444444444444444444",
+      "size": 100,
+      "annotations": {
+        "vnd.docker.reference.digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+        "vnd.docker.reference.type": "attestation-manifest"
+      },
+      "platform": {"os": "unknown", "architecture": "unknown"}
+    }
+  ]
+}`
+
+func TestIndexPlatformsSkipsAttestations(t *testing.T) {
+	m, err := manifest.New(manifest.WithRaw([]byte(testIndexWithAttestations)))
+	if err != nil {
+		t.Fatalf("Failed to parse test index: %v", err)
+	}
+
+	platforms := indexPlatforms(m)
+	expected := map[string]string{
+		"linux/amd64": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"linux/arm64": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+	}
+	if !reflect.DeepEqual(platforms, expected) {
+		t.Errorf("Expected %v, got %v", expected, platforms)
+	}
+}
+
+func TestParsePlatformsSkipsAll(t *testing.T) {
+	platforms, err := parsePlatforms([]string{"all", "linux/amd64"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(platforms) != 1 || platforms[0].String() != "linux/amd64" {
+		t.Errorf("Expected only linux/amd64, got %v", platforms)
+	}
+}
+
+func TestPlatformManifestsFetchesEachDigestOnce(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+	before := client.Stats().ManifestGets
+
+	container := models.Container{Repository: host, Name: "example/app", Tag: "1.0"}
+	result := &models.TagResult{Digest: layout.index, Platforms: models.ArchMap{
+		"linux/amd64": layout.platforms["linux/amd64"],
+		"linux/arm64": layout.platforms["linux/arm64"],
+		// Pinned to the index digest, which has no image of its own
+		"linux/riscv64": layout.index,
+		// An alias of linux/amd64 sharing its digest
+		"linux/amd64/v2": layout.platforms["linux/amd64"],
+	}}
+	manifests, err := client.platformManifests(context.Background(), container, result)
+	if err != nil {
+		t.Fatalf("platformManifests returned an error: %v", err)
+	}
+
+	if len(manifests) != 3 {
+		t.Errorf("Expected the manifests of 3 platforms, got %d", len(manifests))
+	}
+	if _, ok := manifests["linux/riscv64"]; ok {
+		t.Error("Expected the platform pinned to the index to be left out")
+	}
+	if gets := client.Stats().ManifestGets - before; gets != 3 {
+		t.Errorf("Expected 3 manifest GETs, got %d", gets)
+	}
+}
+
+func TestPlatformManifestsHeadOnly(t *testing.T) {
+	client := NewMockClient()
+	client.hubHeadOnly.Store(true)
+
+	container := models.Container{Repository: DockerHub, Name: "library/busybox", Tag: "1.36"}
+	result := &models.TagResult{Platforms: models.ArchMap{"linux/amd64": "sha256:" + strings.Repeat("1", 64)}}
+	if _, err := client.platformManifests(context.Background(), container, result); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded in HEAD-only mode, got %v", err)
+	}
+}
+
+func TestResolveAllPlatformsUnknownPlatform(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+	dir := t.TempDir()
+	r, err := ref.New("ocidir://" + dir + ":1.0")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	// A single-platform image whose config has no os or architecture
+	config := []byte("{}")
+	configDesc := descriptor.Descriptor{MediaType: mediatype.OCI1ImageConfig, Digest: digest.FromBytes(config), Size: int64(len(config))}
+	if _, err := client.client.BlobPut(ctx, r, configDesc, bytes.NewReader(config)); err != nil {
+		t.Fatalf("Failed to push config: %v", err)
+	}
+	m, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: mediatype.OCI1Manifest,
+		Config:    configDesc,
+		Layers:    []descriptor.Descriptor{},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+	if err := client.client.ManifestPut(ctx, r, m); err != nil {
+		t.Fatalf("Failed to push manifest: %v", err)
+	}
+
+	container := models.Container{Repository: "ghcr.io", Name: "example/app", Tag: "1.0", Architectures: []string{"all"}, Source: "ocidir://" + dir}
+	_, err = client.resolveContainer(ctx, container)
+	if !errors.Is(err, ErrPlatformNotFound) || !strings.Contains(err.Error(), "ghcr.io/example/app:1.0") {
+		t.Errorf("Expected ErrPlatformNotFound naming the image, got %v", err)
+	}
+
+	// Naming the architecture pins the image's digest
+	container.Architectures = []string{"linux/amd64"}
+	result, err := client.resolveContainer(ctx, container)
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+	if result.Platforms["linux/amd64"] != m.GetDescriptor().Digest.String() {
+		t.Errorf("Expected linux/amd64 to be pinned to %s, got %v", m.GetDescriptor().Digest, result.Platforms)
+	}
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"sync"
+)
+
+// job is a single unit of work against a registry
+type job struct {
+	registry string // Registry hostname the job talks to, used for per-registry limits
+	run      func() error
+}
+
+// pool runs jobs with a global concurrency limit and a per-registry concurrency limit
+type pool struct {
+	global      chan struct{}
+	perRegistry int
+
+	mu         sync.Mutex
+	registries map[string]chan struct{}
+}
+
+// newPool creates a pool, treating limits below one as one
+func newPool(concurrency, registryConcurrency int) *pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if registryConcurrency < 1 {
+		registryConcurrency = 1
+	}
+
+	return &pool{
+		global:      make(chan struct{}, concurrency),
+		perRegistry: registryConcurrency,
+		registries:  map[string]chan struct{}{},
+	}
+}
+
+// registrySlots returns the semaphore for a registry, creating it on first use
+func (p *pool) registrySlots(registry string) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, exists := p.registries[registry]
+	if !exists {
+		slots = make(chan struct{}, p.perRegistry)
+		p.registries[registry] = slots
+	}
+	return slots
+}
+
+// run executes all jobs and returns the error of the first failing job in slice order.
+// Once a job fails, jobs later in the slice that have not started yet are skipped,
+// so the error returned is the same one a sequential run would have stopped at.
+func (p *pool) run(jobs []job) error {
+	errs := make([]error, len(jobs))
+
+	var mu sync.Mutex
+	firstFailed := len(jobs)
+
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func(i int, j job) {
+			defer wg.Done()
+
+			// Take the registry slot before the global one so a busy registry
+			// does not hold global slots that other registries could use
+			slots := p.registrySlots(j.registry)
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			p.global <- struct{}{}
+			defer func() { <-p.global }()
+
+			// Skip the job if an earlier job has already failed
+			mu.Lock()
+			skip := i > firstFailed
+			mu.Unlock()
+			if skip {
+				return
+			}
+
+			if err := j.run(); err != nil {
+				errs[i] = err
+				mu.Lock()
+				if i < firstFailed {
+					firstFailed = i
+				}
+				mu.Unlock()
+			}
+		}(i, j)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
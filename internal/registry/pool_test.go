@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolRunsAllJobs(t *testing.T) {
+	var count atomic.Int32
+	jobs := make([]job, 20)
+	for i := range jobs {
+		jobs[i] = job{registry: "docker.io", run: func() error {
+			count.Add(1)
+			return nil
+		}}
+	}
+
+	if err := newPool(4, 2).run(jobs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if count.Load() != 20 {
+		t.Errorf("Expected 20 jobs to run, got %d", count.Load())
+	}
+}
+
+func TestPoolRespectsRegistryLimit(t *testing.T) {
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	maxInFlight := map[string]int{}
+
+	var jobs []job
+	for i := 0; i < 12; i++ {
+		registry := "docker.io"
+		if i%2 == 0 {
+			registry = "ghcr.io"
+		}
+		jobs = append(jobs, job{registry: registry, run: func() error {
+			mu.Lock()
+			inFlight[registry]++
+			if inFlight[registry] > maxInFlight[registry] {
+				maxInFlight[registry] = inFlight[registry]
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inFlight[registry]--
+			mu.Unlock()
+			return nil
+		}})
+	}
+
+	if err := newPool(8, 2).run(jobs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for registry, peak := range maxInFlight {
+		if peak > 2 {
+			t.Errorf("Expected at most 2 concurrent jobs for %s, got %d", registry, peak)
+		}
+	}
+}
+
+func TestPoolReturnsFirstErrorInOrder(t *testing.T) {
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+
+	jobs := []job{
+		{registry: "a", run: func() error { return nil }},
+		{registry: "b", run: func() error {
+			// Fail late so the later job fails first in wall-clock time
+			time.Sleep(20 * time.Millisecond)
+			return errFirst
+		}},
+		{registry: "c", run: func() error { return errSecond }},
+	}
+
+	err := newPool(3, 1).run(jobs)
+	if !errors.Is(err, errFirst) {
+		t.Errorf("Expected the error of the earliest failing job, got %v", err)
+	}
+}
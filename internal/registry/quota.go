@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// DockerHub is the registry name used for images hosted on Docker Hub
+const DockerHub = config.DockerRegistry
+
+// quotaCheckRef is the image Docker documents for checking the pull limit.
+// Checking it with a HEAD request does not count as a pull.
+const quotaCheckRef = "docker.io/ratelimitpreview/test:latest"
+
+// Policies for when Docker Hub entries exceed the remaining pull quota
+const (
+	QuotaFail     = "fail"      // Refuse to start the run
+	QuotaHeadOnly = "head-only" // Only reuse locked results confirmed with HEAD requests
+	QuotaIgnore   = "ignore"    // Run anyway and risk hitting the limit
+)
+
+// ErrQuotaExceeded is returned when a run needs more Docker Hub pulls than remain
+var ErrQuotaExceeded = errors.New("docker hub pull quota exceeded")
+
+// Quota is the pull rate limit reported by Docker Hub
+type Quota struct {
+	Reported  bool          // Whether a limit was reported at all (some accounts are not limited)
+	Limit     int           // Pulls allowed per window
+	Remaining int           // Pulls left in the current window
+	Window    time.Duration // Length of the window, if reported
+}
+
+// String describes the quota for display
+func (q Quota) String() string {
+	if !q.Reported {
+		return "no pull limit reported"
+	}
+	if q.Window > 0 {
+		return fmt.Sprintf("%d of %d pulls remaining per %s", q.Remaining, q.Limit, q.Window)
+	}
+	return fmt.Sprintf("%d of %d pulls remaining", q.Remaining, q.Limit)
+}
+
+// CheckQuota asks Docker Hub for the current pull quota
+func (c *Client) CheckQuota(ctx context.Context) (Quota, error) {
+	r, err := ref.New(quotaCheckRef)
+	if err != nil {
+		return Quota{}, fmt.Errorf("failed to create image reference for %s: %w", quotaCheckRef, err)
+	}
+
+	if c.opts.Offline {
+		return Quota{}, fmt.Errorf("%w: the docker hub quota can't be checked offline", ErrOffline)
+	}
+
+	// Docker Hub is asked directly, since mirrors don't report its quota
+	var m manifest.Manifest
+	err = c.retry(ctx, r.Registry, "HEAD "+r.CommonName(), func(ctx context.Context) error {
+		c.manifestHeads.Add(1)
+		var err error
+		m, err = c.client.ManifestHead(ctx, r)
+		return err
+	})
+	if err != nil {
+		return Quota{}, fmt.Errorf("failed to check docker hub quota: %w", err)
+	}
+
+	return quotaFromManifest(m), nil
+}
+
+// PreflightQuota checks that the Docker Hub entries in the config fit in the remaining
+// pull quota before any of them are resolved, applying the configured policy if not.
+// Nothing is checked when Docker Hub is mirrored, since the mirrors are queried instead,
+// or offline, since no pulls are made.
+func (c *Client) PreflightQuota(ctx context.Context, containersConfig *models.ContainersConfig) (Quota, error) {
+	pulls := c.HubPulls(containersConfig)
+	if pulls == 0 || len(containersConfig.Mirrors[DockerHub]) > 0 || c.opts.Offline {
+		return Quota{}, nil
+	}
+
+	quota, err := c.CheckQuota(ctx)
+	if err != nil || !quota.Reported || pulls <= quota.Remaining {
+		return quota, err
+	}
+
+	switch c.opts.QuotaExceeded {
+	case QuotaHeadOnly:
+		c.logf("Docker Hub has %d pulls left for up to %d needed, switching to HEAD-only mode\n", quota.Remaining, pulls)
+		c.hubHeadOnly.Store(true)
+	case QuotaIgnore:
+		c.logf("Docker Hub has %d pulls left for up to %d needed, continuing anyway\n", quota.Remaining, pulls)
+	default:
+		return quota, fmt.Errorf("%w: docker.io entries need up to %d pulls but only %d remain",
+			ErrQuotaExceeded, pulls, quota.Remaining)
+	}
+
+	return quota, nil
+}
+
+// HubPulls returns the most Docker Hub manifest pulls resolving the config can take.
+// Every docker.io entry needs one for its tag, and more when it is looked up in more
+// detail. Pulls answered by the lock or the cache aren't known in advance, so they are
+// counted too.
+func (c *Client) HubPulls(containersConfig *models.ContainersConfig) int {
+	pulls := 0
+	for _, container := range containersConfig.Containers {
+		if container.Repository == DockerHub && container.Source == "" {
+			pulls += c.maxPulls(container)
+		}
+	}
+	return pulls
+}
+
+// assumedPlatforms is how many platforms an image is counted as having when every platform is
+// pinned, since the number isn't known before its index is read
+const assumedPlatforms = 8
+
+// maxPulls returns the most manifest pulls resolving a container can take: one for its tag,
+// up to two (an index and a platform manifest) per candidate read to sort tags by creation time,
+// and one per platform when platform images are read for their metadata or sizes
+func (c *Client) maxPulls(container models.Container) int {
+	pulls := 1
+	if container.TagRegex != "" && container.TagSortStrategy() == tags.SortNewestCreated {
+		pulls += 2 * maxCreatedCandidates
+	}
+	if c.opts.Metadata || c.opts.Sizes {
+		pulls += platformCount(container)
+	}
+	return pulls
+}
+
+// platformCount returns how many platforms of a container are pinned, assumedPlatforms if all of them
+func platformCount(container models.Container) int {
+	if container.AllPlatforms() {
+		return assumedPlatforms
+	}
+	platforms, err := parsePlatforms(container.Architectures)
+	if err != nil {
+		return len(container.Architectures)
+	}
+	return len(platforms)
+}
+
+// LastQuota returns the most recent quota Docker Hub reported during this run
+func (c *Client) LastQuota() Quota {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+	return c.quota
+}
+
+// headOnly reports whether manifests must not be downloaded from a registry
+func (c *Client) headOnly(registry string) bool {
+	return registry == DockerHub && c.hubHeadOnly.Load()
+}
+
+// recordQuota remembers the quota reported with a Docker Hub manifest response
+func (c *Client) recordQuota(r ref.Ref, m manifest.Manifest) {
+	if r.Registry != DockerHub || m == nil {
+		return
+	}
+
+	quota := quotaFromManifest(m)
+	if !quota.Reported {
+		return
+	}
+
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+	c.quota = quota
+}
+
+// quotaFromManifest reads the rate limit headers returned with a manifest
+func quotaFromManifest(m manifest.Manifest) Quota {
+	rl := manifest.GetRateLimit(m)
+	if !rl.Set {
+		return Quota{}
+	}
+
+	quota := Quota{Reported: true, Limit: rl.Limit, Remaining: rl.Remain}
+
+	// Docker Hub reports the window as a policy, e.g. "100;w=21600"
+	for _, policy := range rl.Policies {
+		for _, param := range strings.Split(policy, ";") {
+			if seconds, found := strings.CutPrefix(strings.TrimSpace(param), "w="); found {
+				if s, err := strconv.Atoi(seconds); err == nil {
+					quota.Window = time.Duration(s) * time.Second
+				}
+			}
+		}
+	}
+
+	return quota
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestQuotaFromManifest(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+	header.Set("RateLimit-Limit", "100;w=21600")
+	header.Set("RateLimit-Remaining", "76;w=21600")
+
+	m, err := manifest.New(manifest.WithRaw([]byte(testIndex)), manifest.WithHeader(header))
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+
+	quota := quotaFromManifest(m)
+	if !quota.Reported {
+		t.Fatal("Expected quota to be reported")
+	}
+	if quota.Limit != 100 || quota.Remaining != 76 {
+		t.Errorf("Expected 76 of 100 pulls remaining, got %d of %d", quota.Remaining, quota.Limit)
+	}
+	if quota.Window != 6*time.Hour {
+		t.Errorf("Expected a 6h window, got %s", quota.Window)
+	}
+	if quota.String() != "76 of 100 pulls remaining per 6h0m0s" {
+		t.Errorf("Unexpected quota description: %s", quota.String())
+	}
+}
+
+func TestQuotaNotReported(t *testing.T) {
+	quota := quotaFromManifest(newTestIndex(t))
+	if quota.Reported {
+		t.Errorf("Expected no quota without rate limit headers, got %+v", quota)
+	}
+}
+
+func TestHeadOnlyAppliesToDockerHub(t *testing.T) {
+	client := NewMockClient()
+	client.hubHeadOnly.Store(true)
+
+	if !client.headOnly(DockerHub) {
+		t.Error("Expected docker.io to be HEAD-only")
+	}
+	if client.headOnly("ghcr.io") {
+		t.Error("Expected ghcr.io to be unaffected by HEAD-only mode")
+	}
+}
+
+func TestManifestGetHeadOnly(t *testing.T) {
+	client := NewMockClient()
+	client.hubHeadOnly.Store(true)
+
+	r, err := ref.New("docker.io/library/busybox:1.36")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+	if _, err := client.manifestGet(context.Background(), r); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded in HEAD-only mode, got %v", err)
+	}
+}
+
+func TestHubPulls(t *testing.T) {
+	client := NewMockClient()
+	config := &models.ContainersConfig{Containers: []models.Container{
+		{Repository: DockerHub, Name: "library/busybox", Tag: "1.36"},
+		{Repository: DockerHub, Name: "library/postgres", TagRegex: `\d+\.\d+`, TagSort: tags.SortNewestCreated},
+		{Repository: DockerHub, Name: "example/app", Tag: "1.0", Source: "ocidir://build/app"},
+		{Repository: "ghcr.io", Name: "example/app", Tag: "1.0"},
+	}}
+
+	if pulls := client.HubPulls(config); pulls != 2+2*maxCreatedCandidates {
+		t.Errorf("Expected %d pulls, got %d", 2+2*maxCreatedCandidates, pulls)
+	}
+}
+
+func TestHubPullsWithMetadata(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Metadata = true
+	config := &models.ContainersConfig{Containers: []models.Container{
+		{Repository: DockerHub, Name: "library/busybox", Tag: "1.36", Architectures: []string{"linux/amd64", "linux/x86_64", "linux/arm64"}},
+		{Repository: DockerHub, Name: "library/alpine", Tag: "3.20"},
+	}}
+
+	// Aliases count once, and every platform is assumed to be assumedPlatforms
+	if expected := 1 + 2 + 1 + assumedPlatforms; client.HubPulls(config) != expected {
+		t.Errorf("Expected %d pulls, got %d", expected, client.HubPulls(config))
+	}
+}
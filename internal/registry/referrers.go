@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+)
+
+// referrers looks up the artifacts referring to a result's index and platform digests.
+// Registries without the OCI referrers API are asked for the fallback tag instead.
+func (c *Client) referrers(ctx context.Context, container models.Container, result *models.TagResult) error {
+	r, err := c.imageRef(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	// A single-platform image has the same digest for its tag and its platform
+	digests := map[string]bool{result.Digest: true}
+	for _, digest := range result.Platforms {
+		digests[digest] = true
+	}
+
+	result.Referrers = map[string][]models.Referrer{}
+	for digest := range digests {
+		if digest == "" {
+			continue
+		}
+		referrers, err := c.referrerList(ctx, r.SetDigest(digest))
+		if err != nil {
+			return err
+		}
+		result.Referrers[digest] = referrers
+	}
+	return nil
+}
+
+// referrerList lists the artifacts referring to a digest, ordered by artifact type and digest
+func (c *Client) referrerList(ctx context.Context, r ref.Ref) ([]models.Referrer, error) {
+	var rl referrer.ReferrerList
+	err := c.mirrored(ctx, r, "list referrers", func(ctx context.Context, r ref.Ref) error {
+		var err error
+		rl, err = c.client.ReferrerList(ctx, r)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %s: %w", r.CommonName(), err)
+	}
+
+	referrers := []models.Referrer{}
+	for _, desc := range rl.Descriptors {
+		referrers = append(referrers, models.Referrer{
+			ArtifactType: desc.ArtifactType,
+			MediaType:    desc.MediaType,
+			Digest:       desc.Digest.String(),
+		})
+	}
+	sort.Slice(referrers, func(i, j int) bool {
+		if referrers[i].ArtifactType != referrers[j].ArtifactType {
+			return referrers[i].ArtifactType < referrers[j].ArtifactType
+		}
+		return referrers[i].Digest < referrers[j].Digest
+	})
+	return referrers, nil
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types/descriptor"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// attachTestArtifact pushes an artifact of the given type referring to subject into an OCI layout
+func attachTestArtifact(t *testing.T, client *Client, subject ref.Ref, artifactType string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	empty := []byte("{}")
+	emptyDesc := descriptor.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromBytes(empty),
+		Size:      int64(len(empty)),
+	}
+	if _, err := client.client.BlobPut(ctx, subject, emptyDesc, bytes.NewReader(empty)); err != nil {
+		t.Fatalf("Failed to push artifact config: %v", err)
+	}
+
+	head, err := client.client.ManifestHead(ctx, subject)
+	if err != nil {
+		t.Fatalf("Failed to get subject: %v", err)
+	}
+	subjectDesc := head.GetDescriptor()
+	artifact, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned:    v1.ManifestSchemaVersion,
+		MediaType:    "application/vnd.oci.image.manifest.v1+json",
+		ArtifactType: artifactType,
+		Config:       emptyDesc,
+		Layers:       []descriptor.Descriptor{emptyDesc},
+		Subject:      &subjectDesc,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create artifact: %v", err)
+	}
+
+	artifactDigest := artifact.GetDescriptor().Digest.String()
+	if err := client.client.ManifestPut(ctx, subject.SetDigest(artifactDigest), artifact); err != nil {
+		t.Fatalf("Failed to push artifact: %v", err)
+	}
+	return artifactDigest
+}
+
+func TestResolveReferrers(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Referrers = true
+	layout := writeTestLayout(t)
+
+	container := models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64", "linux/arm64"},
+		Source:        "ocidir://" + layout.dir,
+	}
+	r, err := client.imageRef(context.Background(), container)
+	if err != nil {
+		t.Fatalf("imageRef returned an error: %v", err)
+	}
+	sbom := attachTestArtifact(t, client, r.SetDigest(layout.platforms["linux/amd64"]), "application/spdx+json")
+
+	result, err := client.resolveContainer(context.Background(), container)
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+
+	expected := map[string][]models.Referrer{
+		layout.index: {},
+		layout.platforms["linux/amd64"]: {{
+			ArtifactType: "application/spdx+json",
+			MediaType:    "application/vnd.oci.image.manifest.v1+json",
+			Digest:       sbom,
+		}},
+		layout.platforms["linux/arm64"]: {},
+	}
+	if !reflect.DeepEqual(result.Referrers, expected) {
+		t.Errorf("Expected referrers %+v, got %+v", expected, result.Referrers)
+	}
+
+	// The rich output places them next to the digest they refer to
+	rich := models.TagResults{result}.Rich()["ghcr.io"]["example/app"]["1.0"]
+	if len(rich.Referrers) != 0 || len(rich.Platforms["linux/amd64"].Referrers) != 1 {
+		t.Errorf("Expected the SBOM on linux/amd64 only, got %+v", rich)
+	}
+}
@@ -0,0 +1,238 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types/errs"
+)
+
+// RetryPolicy controls how failed registry operations are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts per operation, including the first one
+	BaseDelay   time.Duration // Delay before the first retry, doubled for every retry after it
+	MaxDelay    time.Duration // Upper bound for the delay between two attempts
+	Jitter      float64       // Fraction of each delay that is randomized, between 0 and 1
+}
+
+// DefaultRetryPolicy returns the retry policy used when nothing is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// delay returns how long to wait before the given retry (1 for the first retry)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	// Spread the delay by +/- the jitter fraction so parallel lookups don't retry in lockstep
+	if p.Jitter > 0 {
+		spread := float64(d) * p.Jitter
+		d = time.Duration(float64(d) - spread + rand.Float64()*2*spread)
+	}
+	return d
+}
+
+// httpStatusPattern extracts the status code regclient appends to HTTP errors
+var httpStatusPattern = regexp.MustCompile(`\[http (\d{3})\]`)
+
+// isTransient reports whether an error is worth retrying
+func isTransient(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, errs.ErrNotFound),
+		errors.Is(err, errs.ErrHTTPUnauthorized):
+		return false
+	case errors.Is(err, errs.ErrHTTPRateLimit),
+		errors.Is(err, errs.ErrBackoffLimit),
+		errors.Is(err, errs.ErrRetryLimitExceeded),
+		errors.Is(err, errRequestTimeout),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	if errors.Is(err, errs.ErrHTTPStatus) {
+		match := httpStatusPattern.FindStringSubmatch(err.Error())
+		if match == nil {
+			return false
+		}
+		status, _ := strconv.Atoi(match[1])
+		switch status {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// errRequestTimeout is returned when a single attempt exceeds the request timeout
+var errRequestTimeout = errors.New("request timed out")
+
+// retry runs op until it succeeds, fails with an error that isn't transient, or runs out of attempts.
+// The registry is used to honor any Retry-After header the registry sent with its last failure.
+// Each attempt gets its own context, limited by the request timeout if one is set, so a hung
+// request is abandoned and retried without giving up on the whole operation.
+func (c *Client) retry(ctx context.Context, registry, description string, op func(ctx context.Context) error) error {
+	policy := c.opts.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, op)
+		if err == nil || attempt >= policy.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		// Wait for the backoff delay, or longer if the registry asked for it
+		wait := policy.delay(attempt)
+		if until := c.retryAfter.get(registry); time.Until(until) > wait {
+			wait = time.Until(until)
+		}
+
+		c.retries.Add(1)
+		c.logf("Retrying %s in %s (attempt %d of %d): %v\n",
+			description, wait.Round(time.Millisecond), attempt+1, policy.MaxAttempts, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt runs op once, within the request timeout if one is set
+func (c *Client) attempt(ctx context.Context, op func(ctx context.Context) error) error {
+	if c.opts.RequestTimeout <= 0 {
+		return op(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, c.opts.RequestTimeout)
+	defer cancel()
+
+	err := op(attemptCtx)
+	// Only the attempt timed out if the caller's context is still live, which is worth retrying
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", errRequestTimeout, c.opts.RequestTimeout, err)
+	}
+	return err
+}
+
+// retryAfterTransport sends the registry requests regclient makes and remembers the Retry-After
+// header of each response, keyed by host. regclient doesn't return response headers with its errors,
+// so this is the only place they are seen. The header is removed before regclient reads the response,
+// so regclient doesn't wait and retry on its own: the retry policy waits for it instead.
+type retryAfterTransport struct {
+	mu         sync.Mutex
+	until      map[string]time.Time       // Earliest time of the next request, keyed by hostname
+	hosts      map[string]config.Host     // Settings of the configured hosts, keyed by hostname
+	transports map[string]*http.Transport // Transport of each host with its TLS settings, created on first use
+}
+
+// newRetryAfterTransport creates a transport applying the TLS settings of the given hosts
+func newRetryAfterTransport(hosts []config.Host) *retryAfterTransport {
+	t := &retryAfterTransport{
+		until:      map[string]time.Time{},
+		hosts:      map[string]config.Host{},
+		transports: map[string]*http.Transport{},
+	}
+	for _, host := range hosts {
+		t.hosts[host.Hostname] = host
+	}
+	return t
+}
+
+// get returns the time before which the registry asked not to be retried
+func (t *retryAfterTransport) get(registry string) time.Time {
+	hostname := config.HostNewName(registry).Hostname
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.until[hostname]
+}
+
+// RoundTrip implements http.RoundTripper, recording and removing the Retry-After header of the response
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.transport(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.Header.Get("Retry-After") == "" {
+		return resp, err
+	}
+
+	until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	resp.Header.Del("Retry-After")
+	if !ok {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.until[req.URL.Host]) {
+		t.until[req.URL.Host] = until
+	}
+	return resp, nil
+}
+
+// transport returns the transport for a host, configured with its TLS settings.
+// regclient only applies them to an *http.Transport, so they are applied here instead.
+func (t *retryAfterTransport) transport(hostname string) (*http.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transport, ok := t.transports[hostname]; ok {
+		return transport, nil
+	}
+
+	host, ok := t.hosts[hostname]
+	if !ok {
+		host = *config.HostNewName(hostname)
+	}
+	tlsConfig, err := hostTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	t.transports[hostname] = transport
+	return transport, nil
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,239 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/errs"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := policy.delay(i + 1); got != want {
+			t.Errorf("Expected delay %s for retry %d, got %s", want, i+1, got)
+		}
+	}
+
+	// Jitter keeps the delay within the configured fraction
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := policy.delay(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("Expected jittered delay between 50ms and 150ms, got %s", d)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{fmt.Errorf("%w [http 429]", errs.ErrHTTPRateLimit), true},
+		{fmt.Errorf("%w: Bad Gateway [http 502]", errs.ErrHTTPStatus), true},
+		{fmt.Errorf("%w: Service Unavailable [http 503]", errs.ErrHTTPStatus), true},
+		{fmt.Errorf("%w: Bad Request [http 400]", errs.ErrHTTPStatus), false},
+		{fmt.Errorf("%w [http 404]", errs.ErrNotFound), false},
+		{fmt.Errorf("%w [http 401]", errs.ErrHTTPUnauthorized), false},
+		{context.Canceled, false},
+		{errors.New("something else"), false},
+	}
+
+	for _, test := range tests {
+		if got := isTransient(test.err); got != test.transient {
+			t.Errorf("Expected isTransient(%v) to be %t, got %t", test.err, test.transient, got)
+		}
+	}
+}
+
+func TestRetryStopsOnSuccess(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Retry = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}
+
+	attempts := 0
+	err := client.retry(context.Background(), "ghcr.io", "GET test", func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("%w: Bad Gateway [http 502]", errs.ErrHTTPStatus)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if retries := client.Stats().Retries; retries != 2 {
+		t.Errorf("Expected 2 retries to be counted, got %d", retries)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	attempts := 0
+	err := client.retry(context.Background(), "ghcr.io", "GET test", func(context.Context) error {
+		attempts++
+		return fmt.Errorf("%w [http 429]", errs.ErrHTTPRateLimit)
+	})
+	if !errors.Is(err, errs.ErrHTTPRateLimit) {
+		t.Errorf("Expected the last error to be returned, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// Permanent errors are not retried
+	attempts = 0
+	_ = client.retry(context.Background(), "ghcr.io", "GET test", func(context.Context) error {
+		attempts++
+		return fmt.Errorf("%w [http 404]", errs.ErrNotFound)
+	})
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt for a permanent error, got %d", attempts)
+	}
+}
+
+func TestRetryAbandonsHungRequest(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	client.opts.RequestTimeout = 10 * time.Millisecond
+
+	// The first attempt hangs until its context times out, the second succeeds
+	attempts := 0
+	err := client.retry(context.Background(), "ghcr.io", "GET test", func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success after the hung request was retried, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	client.opts.RequestTimeout = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := client.retry(ctx, "ghcr.io", "GET test", func(ctx context.Context) error {
+		attempts++
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt once canceled, got %d", attempts)
+	}
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	transport := newRetryAfterTransport(nil)
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/v2/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get("Retry-After") != "" {
+		t.Error("Expected Retry-After to be removed from the response")
+	}
+	if wait := time.Until(transport.get(serverURL.Host)); wait < 25*time.Second || wait > 30*time.Second {
+		t.Errorf("Expected %s to be blocked for about 30s, got %s", serverURL.Host, wait)
+	}
+	if !transport.get("ghcr.io").IsZero() {
+		t.Error("Expected no Retry-After for ghcr.io")
+	}
+}
+
+func TestRegclientDoesNotRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	opts := DefaultOptions()
+	opts.Retry = RetryPolicy{MaxAttempts: 1}
+	client, err := NewClient(&models.ContainersConfig{
+		Registries: map[string]models.RegistryConfig{serverURL.Host: {TLS: "disabled"}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("NewClient returned an error: %v", err)
+	}
+	r, _ := ref.New(serverURL.Host + "/example/app:1.0")
+
+	// Neither the Retry-After header nor the failures make regclient wait or try again
+	start := time.Now()
+	for range 2 {
+		if _, err := client.manifestHead(context.Background(), r); err == nil {
+			t.Fatal("Expected the request to fail")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected regclient not to back off, took %s", elapsed)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests.Load())
+	}
+	if wait := time.Until(client.retryAfter.get(serverURL.Host)); wait <= 0 {
+		t.Error("Expected the Retry-After header to be recorded for the retry policy")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if until, ok := parseRetryAfter("120", now); !ok || !until.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected seconds to be parsed, got %s %t", until, ok)
+	}
+
+	if until, ok := parseRetryAfter("Wed, 01 Jan 2025 12:05:00 GMT", now); !ok || !until.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("Expected HTTP date to be parsed, got %s %t", until, ok)
+	}
+
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("Expected invalid value to be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/verify"
+	"github.com/regclient/regclient/types/descriptor"
+	"github.com/regclient/regclient/types/errs"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Annotations cosign stores a signature's details in, on each layer of the signature manifest
+const (
+	annotationSignature   = "dev.cosignproject.cosign/signature"
+	annotationCertificate = "dev.sigstore.cosign/certificate"
+	annotationChain       = "dev.sigstore.cosign/chain"
+	annotationBundle      = "dev.sigstore.cosign/bundle"
+)
+
+// maxPayloadSize limits the signature payloads downloaded, which are a few hundred bytes
+const maxPayloadSize = 1 << 20
+
+// verifySignature checks that the digest a container resolved to has a cosign signature
+// satisfying its verify settings. Signatures are read from the tag cosign stores them
+// under (sha256-<hex>.sig), in the same repository as the image.
+func (c *Client) verifySignature(ctx context.Context, container models.Container, digest string) error {
+	if digest == "" {
+		return fmt.Errorf("%w: the digest to verify is not known", verify.ErrNoValidSignature)
+	}
+	verifier, err := verify.New(*container.Verify)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.imageRef(ctx, container)
+	if err != nil {
+		return err
+	}
+	signatures, err := c.signatures(ctx, r.SetTag(signatureTag(digest)))
+	if err != nil {
+		return err
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("%w: %s is not signed", verify.ErrNoValidSignature, digest)
+	}
+
+	problems := []string{}
+	for _, signature := range signatures {
+		err := verifier.Verify(digest, signature)
+		if err == nil {
+			c.logf("Verified signature of %s by %s\n", container.Reference(), verifier.Describe())
+			return nil
+		}
+		problems = append(problems, err.Error())
+	}
+	return fmt.Errorf("%w by %s for %s: %s", verify.ErrNoValidSignature, verifier.Describe(), digest, strings.Join(problems, "; "))
+}
+
+// signatureTag returns the tag cosign stores the signatures of a digest under
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// signatures reads every signature from a cosign signature manifest, which has
+// one layer per signature. A missing signature manifest means there are none.
+// In HEAD-only mode, a HEAD request finds out whether it exists without a pull.
+func (c *Client) signatures(ctx context.Context, r ref.Ref) ([]verify.Signature, error) {
+	if c.headOnly(r.Registry) && !isLocal(r) {
+		if _, err := c.manifestHead(ctx, r); errors.Is(err, errs.ErrNotFound) {
+			return nil, nil
+		}
+	}
+	m, err := c.manifestGet(ctx, r)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signatures %s: %w", r.CommonName(), err)
+	}
+	imager, ok := m.(manifest.Imager)
+	if !ok {
+		return nil, fmt.Errorf("signatures %s are not an image manifest", r.CommonName())
+	}
+	layers, err := imager.GetLayers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signatures %s: %w", r.CommonName(), err)
+	}
+
+	signatures := []verify.Signature{}
+	for _, layer := range layers {
+		if layer.Annotations[annotationSignature] == "" {
+			continue
+		}
+		payload, err := c.blob(ctx, r, layer)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, verify.Signature{
+			Payload:     payload,
+			Signature:   layer.Annotations[annotationSignature],
+			Certificate: layer.Annotations[annotationCertificate],
+			Chain:       layer.Annotations[annotationChain],
+			Bundle:      layer.Annotations[annotationBundle],
+		})
+	}
+	return signatures, nil
+}
+
+// blob downloads a small blob from the registry or its mirrors, using the on-disk cache
+func (c *Client) blob(ctx context.Context, r ref.Ref, desc descriptor.Descriptor) ([]byte, error) {
+	if desc.Size > maxPayloadSize {
+		return nil, fmt.Errorf("blob %s is too large (%d bytes)", desc.Digest, desc.Size)
+	}
+	if c.opts.Cache != nil {
+		if _, data, ok := c.opts.Cache.GetBlob(desc.Digest.String()); ok {
+			c.cacheHits.Add(1)
+			return data, nil
+		}
+	}
+
+	var data []byte
+	err := c.mirrored(ctx, r, "GET blob", func(ctx context.Context, r ref.Ref) error {
+		reader, err := c.client.BlobGet(ctx, r, desc)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		data, err = reader.RawBody()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", desc.Digest, err)
+	}
+
+	if c.opts.Cache != nil {
+		if err := c.opts.Cache.PutBlob(desc.Digest.String(), desc.MediaType, data); err != nil {
+			c.logf("Failed to cache blob %s: %v\n", desc.Digest, err)
+		}
+	}
+	return data, nil
+}
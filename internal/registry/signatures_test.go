@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/verify"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types/descriptor"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// newSigningKey generates a P-256 key like the ones cosign and Rekor use
+func newSigningKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+// signData signs the SHA-256 of data like cosign and Rekor do
+func signData(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return signature
+}
+
+// writeSigningKey writes the PEM public key of a signing key and returns its path
+func writeSigningKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	return path
+}
+
+// rekorBundle creates the transparency log bundle Rekor returns for a signature of payload
+func rekorBundle(t *testing.T, rekorKey *ecdsa.PrivateKey, payload, signature []byte) string {
+	t.Helper()
+	hash := sha256.Sum256(payload)
+	body, _ := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data":      map[string]any{"hash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(hash[:])}},
+			"signature": map[string]any{"content": signature},
+		},
+	})
+	der, _ := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	logID := sha256.Sum256(der)
+	entry := map[string]any{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": time.Now().Unix(),
+		"logID":          hex.EncodeToString(logID[:]),
+		"logIndex":       1,
+	}
+	canonical, _ := json.Marshal(entry)
+	bundle, _ := json.Marshal(map[string]any{"SignedEntryTimestamp": signData(t, rekorKey, canonical), "Payload": entry})
+	return string(bundle)
+}
+
+// signTestImage signs a digest with a new key the way cosign does, recording the signature in
+// the log of rekorKey and pushing the signature manifest under its sha256-<hex>.sig tag, and
+// returns the verify settings accepting it
+func signTestImage(t *testing.T, client *Client, r ref.Ref, imageDigest string, rekorKey *ecdsa.PrivateKey) *models.VerifyConfig {
+	t.Helper()
+	ctx := context.Background()
+
+	key := newSigningKey(t)
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"ghcr.io/example/app"},"image":{"docker-manifest-digest":"` +
+		imageDigest + `"},"type":"cosign container image signature"},"optional":null}`)
+	signature := signData(t, key, payload)
+
+	config := []byte("{}")
+	configDesc := descriptor.Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digest.FromBytes(config), Size: int64(len(config))}
+	payloadDesc := descriptor.Descriptor{
+		MediaType: "application/vnd.dev.cosign.simplesigning.v1+json",
+		Digest:    digest.FromBytes(payload),
+		Size:      int64(len(payload)),
+		Annotations: map[string]string{
+			annotationSignature: base64.StdEncoding.EncodeToString(signature),
+			annotationBundle:    rekorBundle(t, rekorKey, payload, signature),
+		},
+	}
+	for _, blob := range []struct {
+		desc descriptor.Descriptor
+		data []byte
+	}{{configDesc, config}, {payloadDesc, payload}} {
+		if _, err := client.client.BlobPut(ctx, r, blob.desc, bytes.NewReader(blob.data)); err != nil {
+			t.Fatalf("Failed to push blob: %v", err)
+		}
+	}
+
+	m, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Config:    configDesc,
+		Layers:    []descriptor.Descriptor{payloadDesc},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create signature manifest: %v", err)
+	}
+	if err := client.client.ManifestPut(ctx, r.SetTag(signatureTag(imageDigest)), m); err != nil {
+		t.Fatalf("Failed to push signature manifest: %v", err)
+	}
+
+	return &models.VerifyConfig{Key: writeSigningKey(t, key), RekorKey: writeSigningKey(t, rekorKey)}
+}
+
+func TestVerifySignature(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+	rekorKey := newSigningKey(t)
+
+	container := models.Container{
+		Repository:    host,
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64"},
+	}
+	r, err := client.imageRef(context.Background(), container)
+	if err != nil {
+		t.Fatalf("imageRef returned an error: %v", err)
+	}
+
+	// Unsigned images are refused
+	other := signTestImage(t, client, r, "sha256:"+strings.Repeat("0", 64), rekorKey)
+	container.Verify = other
+	if _, err := client.resolveContainer(context.Background(), container); !errors.Is(err, verify.ErrNoValidSignature) {
+		t.Errorf("Expected ErrNoValidSignature for an unsigned image, got %v", err)
+	}
+
+	// Signed images are accepted with the publisher's key only
+	container.Verify = signTestImage(t, client, r, layout.index, rekorKey)
+	if _, err := client.resolveContainer(context.Background(), container); err != nil {
+		t.Errorf("Expected the signed image to be accepted, got %v", err)
+	}
+
+	container.Verify = other
+	if _, err := client.resolveContainer(context.Background(), container); !errors.Is(err, verify.ErrNoValidSignature) {
+		t.Errorf("Expected ErrNoValidSignature for another key, got %v", err)
+	}
+}
+
+func TestVerifySignatureFromLocalSource(t *testing.T) {
+	client := NewMockClient()
+	layout := writeTestLayout(t)
+
+	container := models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64"},
+		Source:        "ocidir://" + layout.dir,
+	}
+	r, err := client.imageRef(context.Background(), container)
+	if err != nil {
+		t.Fatalf("imageRef returned an error: %v", err)
+	}
+
+	// Signatures are read from the source, like the image
+	container.Verify = signTestImage(t, client, r, layout.index, newSigningKey(t))
+	if _, err := client.resolveContainer(context.Background(), container); err != nil {
+		t.Errorf("Expected the signed image to be accepted, got %v", err)
+	}
+}
+
+func TestSignaturesHeadOnly(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+	r, err := ref.New(host + "/example/app")
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+	signTestImage(t, client, r, layout.index, newSigningKey(t))
+
+	// The registry stands in for Docker Hub, where HEAD-only mode applies
+	client.mirrors = map[string][]string{DockerHub: {host}}
+	client.hubHeadOnly.Store(true)
+	hubRef, err := ref.New(DockerHub + "/example/app:" + signatureTag(layout.index))
+	if err != nil {
+		t.Fatalf("Failed to create reference: %v", err)
+	}
+
+	// A signature manifest that exists can't be downloaded
+	before := client.Stats().ManifestGets
+	if _, err := client.signatures(context.Background(), hubRef); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded in HEAD-only mode, got %v", err)
+	}
+	if gets := client.Stats().ManifestGets - before; gets != 0 {
+		t.Errorf("Expected no manifest GET, got %d", gets)
+	}
+}
+
+func TestSignatureTag(t *testing.T) {
+	if tag := signatureTag("sha256:abcd"); tag != "sha256-abcd.sig" {
+		t.Errorf("Expected sha256-abcd.sig, got %s", tag)
+	}
+}
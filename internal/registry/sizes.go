@@ -0,0 +1,24 @@
+package registry
+
+import (
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient/types/manifest"
+)
+
+// sizes sums the compressed size of the layers of each platform of a result, which is
+// how much is downloaded to pull it, from the manifests read by platformManifests
+func sizes(result *models.TagResult, manifests map[string]manifest.Manifest) {
+	result.Sizes = map[string]int64{}
+	for key, m := range manifests {
+		layers, err := m.(manifest.Imager).GetLayers()
+		if err != nil {
+			continue
+		}
+
+		var size int64
+		for _, layer := range layers {
+			size += layer.Size
+		}
+		result.Sizes[key] = size
+	}
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+func TestResolveSizes(t *testing.T) {
+	client := NewMockClient()
+	client.opts.Sizes = true
+	layout := writeTestLayout(t)
+
+	result, err := client.resolveContainer(context.Background(), models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64", "linux/arm64"},
+		Source:        "ocidir://" + layout.dir,
+	})
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+
+	for _, key := range []string{"linux/amd64", "linux/arm64"} {
+		if result.Sizes[key] != layout.sizes[key] {
+			t.Errorf("Expected size %d for %s, got %d", layout.sizes[key], key, result.Sizes[key])
+		}
+	}
+}
+
+func TestResolveSizesWithMetadata(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	client.opts.Metadata = true
+	client.opts.Sizes = true
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+	before := client.Stats().ManifestGets
+
+	result, err := client.resolveContainer(context.Background(), models.Container{
+		Repository:    host,
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64", "linux/arm64"},
+	})
+	if err != nil {
+		t.Fatalf("resolveContainer returned an error: %v", err)
+	}
+
+	if len(result.Sizes) != 2 || len(result.Metadata) != 2 {
+		t.Errorf("Expected sizes and metadata for 2 platforms, got %v and %v", result.Sizes, result.Metadata)
+	}
+	// The index and each platform manifest, read once for both
+	if gets := client.Stats().ManifestGets - before; gets != 3 {
+		t.Errorf("Expected 3 manifest GETs, got %d", gets)
+	}
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// isLocal reports whether a reference points to a local OCI layout rather than a registry
+func isLocal(r ref.Ref) bool {
+	return r.Scheme == models.SourceOCIDir
+}
+
+// archiveImport is an archive unpacked into a temporary OCI layout, shared by the containers reading it
+type archiveImport struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// imageRef returns the reference a container's image is read from: its registry,
+// by digest if its image reference pins one, or the local OCI layout or archive given as its source
+func (c *Client) imageRef(ctx context.Context, container models.Container) (ref.Ref, error) {
+	if container.Source == "" {
+		fullRef := fmt.Sprintf("%s/%s:%s", container.Repository, container.Name, container.Tag)
+		r, err := ref.New(fullRef)
+		if err != nil {
+			return ref.Ref{}, fmt.Errorf("failed to create image reference for %s: %w", fullRef, err)
+		}
+		if container.Digest != "" {
+			r = r.SetDigest(container.Digest)
+		}
+		return r, nil
+	}
+
+	source, err := models.ParseSource(container.Source)
+	if err != nil {
+		return ref.Ref{}, err
+	}
+	tag := container.Tag
+	if source.Name != "" && source.Kind == models.SourceOCIDir {
+		tag = source.Name
+	}
+
+	dir := source.Path
+	if source.Kind != models.SourceOCIDir {
+		dir, err = c.importArchive(ctx, source, tag)
+		if err != nil {
+			return ref.Ref{}, err
+		}
+	}
+
+	r, err := ref.New(fmt.Sprintf("%s://%s:%s", models.SourceOCIDir, dir, tag))
+	if err != nil {
+		return ref.Ref{}, fmt.Errorf("failed to create image reference for %s: %w", container.Source, err)
+	}
+	return r, nil
+}
+
+// importArchive unpacks an OCI or Docker archive into a temporary OCI layout, once per archive and image,
+// where the image is tagged with the given tag. Archives holding several images need the source
+// to name one, unless one of the images in an OCI archive already has that tag.
+func (c *Client) importArchive(ctx context.Context, source models.Source, tag string) (string, error) {
+	c.archivesMu.Lock()
+	key := source.Path + "\x00" + source.Name + "\x00" + tag
+	imported, ok := c.archives[key]
+	if !ok {
+		imported = &archiveImport{}
+		c.archives[key] = imported
+	}
+	c.archivesMu.Unlock()
+
+	imported.once.Do(func() {
+		imported.dir, imported.err = c.unpackArchive(ctx, source, tag)
+	})
+	return imported.dir, imported.err
+}
+
+// unpackArchive copies the image selected in an archive into a new temporary OCI layout
+func (c *Client) unpackArchive(ctx context.Context, source models.Source, tag string) (string, error) {
+	file, err := os.Open(source.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s %s: %w", source.Kind, source.Path, err)
+	}
+	defer file.Close()
+
+	dir, err := os.MkdirTemp("", "container-digest-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", source.Path, err)
+	}
+
+	r, err := ref.New(fmt.Sprintf("%s://%s:%s", models.SourceOCIDir, dir, tag))
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to create image reference for %s: %w", source.Path, err)
+	}
+
+	opts := []regclient.ImageOpts{}
+	if source.Name != "" {
+		opts = append(opts, regclient.ImageWithImportName(source.Name))
+	}
+	c.logf("Importing %s %s\n", source.Kind, source.Path)
+	if err := c.client.ImageImport(ctx, r, file, opts...); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to import %s %s: %w", source.Kind, source.Path, err)
+	}
+	return dir, nil
+}
+
+// Close removes the temporary OCI layouts archives were unpacked into
+func (c *Client) Close() error {
+	c.archivesMu.Lock()
+	defer c.archivesMu.Unlock()
+
+	var errs []error
+	for key, imported := range c.archives {
+		if imported.dir != "" {
+			if err := os.RemoveAll(imported.dir); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		delete(c.archives, key)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+// testLayout is an OCI layout written by writeTestLayout
+type testLayout struct {
+	dir       string            // Directory of the layout
+	index     string            // Digest of the index tagged 1.0
+	platforms map[string]string // Digest of each platform's manifest
+	configs   map[string]string // Digest of each platform's config
+	sizes     map[string]int64  // Total size of each platform's layers
+}
+
+// writeTestLayout writes an OCI layout with a complete two-platform image tagged 1.0
+func writeTestLayout(t *testing.T) testLayout {
+	t.Helper()
+	layout := testLayout{dir: t.TempDir(), platforms: map[string]string{}, configs: map[string]string{}, sizes: map[string]int64{}}
+
+	writeBlob := func(content string) (string, int) {
+		sum := sha256.Sum256([]byte(content))
+		path := filepath.Join(layout.dir, "blobs", "sha256", hex.EncodeToString(sum[:]))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create layout directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write blob: %v", err)
+		}
+		return "sha256:" + hex.EncodeToString(sum[:]), len(content)
+	}
+
+	manifests := []string{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		config, configSize := writeBlob(fmt.Sprintf(`{"architecture":%q,"os":"linux","created":"2024-05-01T12:00:00Z",`+
+			`"config":{"Labels":{"org.opencontainers.image.version":"1.0","maintainer":"someone"}},"rootfs":{"type":"layers","diff_ids":[]}}`, arch))
+		layout.configs["linux/"+arch] = config
+		layers := []string{}
+		for _, content := range []string{"base-" + arch, strings.Repeat("app-"+arch, 10)} {
+			layer, layerSize := writeBlob(content)
+			layers = append(layers, fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":%q,"size":%d}`, layer, layerSize))
+			layout.sizes["linux/"+arch] += int64(layerSize)
+		}
+		m, mSize := writeBlob(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q,"size":%d},"layers":[%s]}`,
+			config, configSize, strings.Join(layers, ",")))
+		layout.platforms["linux/"+arch] = m
+		manifests = append(manifests, fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":%q,"size":%d,"platform":{"os":"linux","architecture":%q}}`,
+			m, mSize, arch))
+	}
+	index, indexSize := writeBlob(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[%s]}`,
+		strings.Join(manifests, ",")))
+	layout.index = index
+
+	files := map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.index.v1+json","digest":%q,"size":%d,"annotations":{"org.opencontainers.image.ref.name":"1.0"}}]}`,
+			index, indexSize),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(layout.dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write layout file: %v", err)
+		}
+	}
+	return layout
+}
+
+// writeTestArchive writes the files of an OCI layout into a tarball
+func writeTestArchive(t *testing.T, layout string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	defer tw.Close()
+	if err := tw.AddFS(os.DirFS(layout)); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	return path
+}
+
+func TestResolveTagFromOCIDir(t *testing.T) {
+	client := NewMockClient()
+	layout := writeTestLayout(t)
+
+	// The layout tag defaults to the container's tag
+	for _, source := range []string{"ocidir://" + layout.dir, "ocidir://" + layout.dir + ":1.0"} {
+		result, err := client.ResolveTag(context.Background(), models.Container{
+			Repository:    "ghcr.io",
+			Name:          "example/app",
+			Tag:           "1.0",
+			Architectures: []string{"linux/arm64"},
+			Source:        source,
+		})
+		if err != nil {
+			t.Fatalf("ResolveTag returned an error for %s: %v", source, err)
+		}
+
+		// The output keeps the publish location
+		if result.Repository != "ghcr.io" || result.Name != "example/app" || result.Tag != "1.0" {
+			t.Errorf("Expected the configured reference, got %s/%s:%s", result.Repository, result.Name, result.Tag)
+		}
+		if result.Digest != layout.index {
+			t.Errorf("Expected index digest %s, got %s", layout.index, result.Digest)
+		}
+		if got := result.Platforms["linux/arm64"]; got != layout.platforms["linux/arm64"] {
+			t.Errorf("Expected the arm64 digest from the layout, got %s", got)
+		}
+	}
+}
+
+func TestResolveTagFromOCIArchive(t *testing.T) {
+	client := NewMockClient()
+	layout := writeTestLayout(t)
+	archive := writeTestArchive(t, layout.dir)
+
+	// Archives are read even offline
+	client.opts.Offline = true
+	result, err := client.ResolveTag(context.Background(), models.Container{
+		Repository:    "ghcr.io",
+		Name:          "example/app",
+		Tag:           "1.0",
+		Architectures: []string{"linux/amd64"},
+		Source:        "oci-archive:" + archive,
+	})
+	if err != nil {
+		t.Fatalf("ResolveTag returned an error: %v", err)
+	}
+	if result.Digest != layout.index || result.Platforms["linux/amd64"] != layout.platforms["linux/amd64"] {
+		t.Errorf("Expected the digests from the archive, got %s and %v", result.Digest, result.Platforms)
+	}
+
+	// The temporary layout is removed when the client is closed
+	dirs := []string{}
+	for _, imported := range client.archives {
+		dirs = append(dirs, imported.dir)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", dir)
+		}
+	}
+}
+
+func TestImageRefPinnedDigest(t *testing.T) {
+	client := NewMockClient()
+	const digest = "sha256:6d9ac9237a84afe1516540f40a0fafdc86859b2141954b4d643af7066d598b74"
+
+	r, err := client.imageRef(context.Background(), models.Container{
+		Repository: "docker.io",
+		Name:       "library/busybox",
+		Tag:        "1.36",
+		Digest:     digest,
+	})
+	if err != nil {
+		t.Fatalf("imageRef returned an error: %v", err)
+	}
+	if expected := "docker.io/library/busybox@" + digest; r.CommonName() != expected {
+		t.Errorf("Expected %s, got %s", expected, r.CommonName())
+	}
+}
@@ -0,0 +1,262 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// resolveConstraint lists a repository's tags and picks the highest one
+// satisfying the container's tag constraint
+func (c *Client) resolveConstraint(ctx context.Context, container models.Container) (string, error) {
+	r, err := repositoryRef(container)
+	if err != nil {
+		return "", err
+	}
+
+	tagList, err := c.tagList(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := tags.Semver(tagList, container.TagConstraint, container.TagSuffix)
+	if err != nil {
+		return "", err
+	}
+
+	c.logf("Resolved %s %s to %s\n", r.CommonName(), container.TagDescription(), tag)
+	return tag, nil
+}
+
+// resolveRegex lists a repository's tags and picks the latest one matching the
+// container's tag regex, ordered by its sort strategy
+func (c *Client) resolveRegex(ctx context.Context, container models.Container) (string, error) {
+	r, err := repositoryRef(container)
+	if err != nil {
+		return "", err
+	}
+
+	tagList, err := c.tagList(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	candidates, err := tags.Match(tagList, container.TagRegex)
+	if err != nil {
+		return "", err
+	}
+
+	strategy := container.TagSortStrategy()
+	var sorted, skipped []tags.Candidate
+	if strategy == tags.SortNewestCreated {
+		created, err := c.createdTimes(ctx, r, container, candidates)
+		if err != nil {
+			return "", err
+		}
+		sorted, skipped = tags.SortByCreated(candidates, created)
+	} else {
+		sorted, skipped, err = tags.Sort(candidates, strategy)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Explain the choice, since a loose pattern can easily pick up unexpected tags
+	c.logf("Tags of %s matching %s: %d of %d\n", r.CommonName(), container.TagDescription(), len(candidates), len(tagList))
+	if len(skipped) > 0 {
+		c.logf("  Ignored as not sortable by %s: %s\n", strategy, tags.Describe(skipped))
+	}
+	if len(sorted) > 0 {
+		c.logf("  Candidates by %s, latest first: %s\n", strategy, tags.Describe(sorted))
+	}
+
+	if len(sorted) == 0 {
+		return "", fmt.Errorf("%w: no tag matches %s and sorts by %s", tags.ErrNoMatchingTag, container.TagDescription(), strategy)
+	}
+
+	c.logf("Resolved %s %s to %s\n", r.CommonName(), container.TagDescription(), sorted[0].Tag)
+	return sorted[0].Tag, nil
+}
+
+// maxCreatedCandidates is the most candidates whose images are read to sort tags by creation time
+const maxCreatedCandidates = 10
+
+// createdTimes reads the creation time of the image each candidate points to.
+// It fails rather than read more than maxCreatedCandidates images.
+// For multi-arch images, the first requested platform is used, or the first platform
+// in the index if every platform is requested. A candidate the lock covers is read
+// from its locked platform digest when a HEAD request shows the tag hasn't moved.
+func (c *Client) createdTimes(ctx context.Context, r ref.Ref, container models.Container, candidates []tags.Candidate) (map[string]time.Time, error) {
+	platforms, err := parsePlatforms(container.Architectures)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading images needs manifest downloads, which HEAD-only mode forbids
+	if c.headOnly(container.Repository) {
+		return nil, fmt.Errorf("%w: sorting the tags of %s by %s needs to download their manifests, which HEAD-only mode prevents",
+			ErrQuotaExceeded, r.CommonName(), tags.SortNewestCreated)
+	}
+
+	if len(candidates) > maxCreatedCandidates {
+		return nil, fmt.Errorf("%d tags of %s match tag_regex %q, but at most %d can be sorted by %s; use a tighter tag_regex",
+			len(candidates), r.CommonName(), container.TagRegex, maxCreatedCandidates, tags.SortNewestCreated)
+	}
+
+	created := map[string]time.Time{}
+	for _, candidate := range candidates {
+		imageRef := r.SetTag(candidate.Tag)
+
+		if digest, ok := c.lockedPlatformDigest(ctx, container, imageRef, platforms); ok {
+			imageRef = imageRef.SetDigest(digest)
+		}
+
+		m, err := c.manifestGet(ctx, imageRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manifest for %s: %w", imageRef.CommonName(), err)
+		}
+
+		// Pick a single image from a multi-arch index
+		if m.IsList() {
+			digest := ""
+			if len(platforms) > 0 {
+				digest, err = platformDigest(m, platforms[0])
+				if err != nil {
+					return nil, fmt.Errorf("failed to read creation time of %s: %w", imageRef.CommonName(), err)
+				}
+			} else {
+				available := availablePlatforms(m)
+				if len(available) == 0 {
+					continue
+				}
+				digest = indexPlatforms(m)[available[0]]
+			}
+
+			imageRef = imageRef.SetDigest(digest)
+			m, err = c.manifestGet(ctx, imageRef)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get manifest for %s: %w", imageRef.CommonName(), err)
+			}
+		}
+
+		imageConfig, known, err := c.imageConfig(ctx, imageRef, m)
+		if err != nil {
+			return nil, err
+		}
+		if known && imageConfig.Created != nil {
+			created[candidate.Tag] = *imageConfig.Created
+		}
+	}
+
+	return created, nil
+}
+
+// lockedPlatformDigest returns the locked digest of the first requested platform of a tag,
+// if the lock has one and a HEAD request shows the tag still points to the locked manifest
+func (c *Client) lockedPlatformDigest(ctx context.Context, container models.Container, r ref.Ref, platforms []models.Platform) (string, bool) {
+	if c.opts.Lock == nil || len(platforms) == 0 {
+		return "", false
+	}
+	entry, exists := c.opts.Lock.Entries[models.LockKey(container.Repository, container.Name, r.Tag)]
+	digest := entry.Platforms[platforms[0].String()]
+	if !exists || entry.Digest == "" || digest == "" {
+		return "", false
+	}
+
+	head, err := c.manifestHead(ctx, r)
+	if err != nil || head.GetDescriptor().Digest.String() != entry.Digest {
+		return "", false
+	}
+	return digest, true
+}
+
+// repositoryRef creates a reference to a container's repository without a tag
+func repositoryRef(container models.Container) (ref.Ref, error) {
+	repository := fmt.Sprintf("%s/%s", container.Repository, container.Name)
+	r, err := ref.New(repository)
+	if err != nil {
+		return ref.Ref{}, fmt.Errorf("failed to create repository reference for %s: %w", repository, err)
+	}
+	return r, nil
+}
+
+// tagList lists every tag in a repository from the registry or its mirrors, retrying transient failures.
+// A fresh list in the cache answers without contacting the registry.
+func (c *Client) tagList(ctx context.Context, r ref.Ref) ([]string, error) {
+	if tagList, ok := c.cachedTagList(r); ok {
+		return tagList, nil
+	}
+
+	var tagList []string
+	err := c.mirrored(ctx, r, "list tags", func(ctx context.Context, r ref.Ref) error {
+		tl, err := c.client.TagList(ctx, r)
+		if err != nil {
+			return err
+		}
+		tagList, err = tl.GetTags()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags for %s: %w", r.CommonName(), err)
+	}
+	c.storeTagList(r, tagList)
+	return tagList, nil
+}
+
+// maxVersionCandidates is the most candidates checked with HEAD requests to find a version tag
+const maxVersionCandidates = 20
+
+// versionTag finds the most specific tag matching the container's version regex that
+// points to the given digest, so a floating tag like latest can be reported as 1.27.3.
+// Candidates are checked with HEAD requests, most specific first, until one matches,
+// giving up after maxVersionCandidates. Offline, candidates missing from the cache are skipped.
+// An empty tag is returned if none of them points to the digest.
+func (c *Client) versionTag(ctx context.Context, container models.Container, digest string) (string, error) {
+	r, err := repositoryRef(container)
+	if err != nil {
+		return "", err
+	}
+
+	tagList, err := c.tagList(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	candidates, err := tags.Match(tagList, container.VersionRegex)
+	if err != nil {
+		return "", err
+	}
+
+	checked := 0
+	for _, candidate := range tags.BySpecificity(candidates) {
+		if candidate.Tag == container.Tag {
+			continue
+		}
+		if checked == maxVersionCandidates {
+			c.logf("Gave up looking for the version of %s:%s after %d of %d tags\n", r.CommonName(), container.Tag, checked, len(candidates))
+			break
+		}
+		checked++
+
+		imageRef := r.SetTag(candidate.Tag)
+		head, err := c.manifestHead(ctx, imageRef)
+		if errors.Is(err, ErrOffline) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check %s: %w", imageRef.CommonName(), err)
+		}
+		if head.GetDescriptor().Digest.String() == digest {
+			c.logf("Resolved %s:%s to version %s\n", r.CommonName(), container.Tag, candidate.Tag)
+			return candidate.Tag, nil
+		}
+	}
+
+	c.logf("No tag of %s matching %q points to %s\n", r.CommonName(), container.VersionRegex, digest)
+	return "", nil
+}
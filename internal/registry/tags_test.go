@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/fdrake/container-digest/internal/tags"
+)
+
+func TestCreatedTimesRejectsTooManyCandidates(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+
+	tagList := []string{}
+	for i := 1; i <= maxCreatedCandidates+1; i++ {
+		tagList = append(tagList, fmt.Sprintf("2024.%d.0", i))
+	}
+	before := client.Stats().ManifestGets
+
+	container := models.Container{Repository: host, Name: "example/app", TagRegex: `.*`, Architectures: []string{"linux/amd64"}}
+	r, err := repositoryRef(container)
+	if err != nil {
+		t.Fatalf("repositoryRef returned an error: %v", err)
+	}
+	candidates, _ := tags.Match(tagList, container.TagRegex)
+	_, err = client.createdTimes(context.Background(), r, container, candidates)
+	if err == nil || !strings.Contains(err.Error(), "tighter tag_regex") {
+		t.Errorf("Expected an error asking for a tighter tag_regex, got %v", err)
+	}
+	if gets := client.Stats().ManifestGets - before; gets != 0 {
+		t.Errorf("Expected no manifest GETs, got %d", gets)
+	}
+}
+
+func TestCreatedTimesReadsEveryCandidate(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+
+	tagList := []string{}
+	for i := 1; i <= maxCreatedCandidates; i++ {
+		tagList = append(tagList, fmt.Sprintf("2024.%d.0", i))
+	}
+	pushTestImage(t, client, layout, host+"/example/app", tagList...)
+
+	container := models.Container{Repository: host, Name: "example/app", TagRegex: `.*`, Architectures: []string{"linux/amd64"}}
+	r, err := repositoryRef(container)
+	if err != nil {
+		t.Fatalf("repositoryRef returned an error: %v", err)
+	}
+	candidates, _ := tags.Match(tagList, container.TagRegex)
+	created, err := client.createdTimes(context.Background(), r, container, candidates)
+	if err != nil {
+		t.Fatalf("createdTimes returned an error: %v", err)
+	}
+	if len(created) != maxCreatedCandidates {
+		t.Errorf("Expected %d creation times, got %d", maxCreatedCandidates, len(created))
+	}
+}
+
+func TestCreatedTimesUsesLock(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+	pushTestImage(t, client, layout, host+"/example/app", "1.0")
+
+	client.opts.Lock = &models.Lock{Entries: map[string]models.LockEntry{
+		models.LockKey(host, "example/app", "1.0"): {
+			Digest:    layout.index,
+			Platforms: models.ArchMap{"linux/amd64": layout.platforms["linux/amd64"]},
+		},
+	}}
+	before := client.Stats().ManifestGets
+
+	container := models.Container{Repository: host, Name: "example/app", Architectures: []string{"linux/amd64"}}
+	r, _ := repositoryRef(container)
+	created, err := client.createdTimes(context.Background(), r, container, []tags.Candidate{{Tag: "1.0", Version: "1.0"}})
+	if err != nil {
+		t.Fatalf("createdTimes returned an error: %v", err)
+	}
+	if _, ok := created["1.0"]; !ok {
+		t.Error("Expected the creation time of the locked tag")
+	}
+	// Only the platform manifest is downloaded, since the lock names it
+	if gets := client.Stats().ManifestGets - before; gets != 1 {
+		t.Errorf("Expected 1 manifest GET, got %d", gets)
+	}
+}
+
+func TestCreatedTimesHeadOnly(t *testing.T) {
+	client := NewMockClient()
+	client.hubHeadOnly.Store(true)
+
+	container := models.Container{Repository: DockerHub, Name: "library/busybox", TagRegex: `.*`, TagSort: tags.SortNewestCreated}
+	r, _ := repositoryRef(container)
+	_, err := client.createdTimes(context.Background(), r, container, []tags.Candidate{{Tag: "1.36", Version: "1.36"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded in HEAD-only mode, got %v", err)
+	}
+}
+
+func TestVersionTagIsBounded(t *testing.T) {
+	client, host := newTestRegistry(t, DefaultOptions())
+	layout := writeTestLayout(t)
+
+	tagList := []string{}
+	for i := 0; i < maxVersionCandidates+5; i++ {
+		tagList = append(tagList, fmt.Sprintf("1.%d.0", i))
+	}
+	pushTestImage(t, client, layout, host+"/example/app", tagList...)
+	before := client.Stats().ManifestHeads
+
+	// None of the version tags points to the digest, so every candidate would be checked without a bound
+	container := models.Container{Repository: host, Name: "example/app", Tag: "latest", VersionRegex: `\d+(\.\d+)*`}
+	version, err := client.versionTag(context.Background(), container, "sha256:"+strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatalf("versionTag returned an error: %v", err)
+	}
+	if version != "" {
+		t.Errorf("Expected no version, got %q", version)
+	}
+	if heads := client.Stats().ManifestHeads - before; heads != maxVersionCandidates {
+		t.Errorf("Expected %d HEAD requests, got %d", maxVersionCandidates, heads)
+	}
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/fdrake/container-digest/internal/models"
+	"github.com/olareg/olareg"
+	oconfig "github.com/olareg/olareg/config"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// newTestRegistry starts an in-memory registry and returns its host with a client
+// connecting to it over plain HTTP, set up like NewClient does for real registries
+func newTestRegistry(t *testing.T, opts Options) (*Client, string) {
+	t.Helper()
+	handler := olareg.New(oconfig.Config{Storage: oconfig.ConfigStorage{StoreType: oconfig.StoreMem}})
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		_ = handler.Close()
+	})
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+	host := serverURL.Host
+
+	client, err := NewClient(&models.ContainersConfig{
+		Registries: map[string]models.RegistryConfig{host: {TLS: "disabled"}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("NewClient returned an error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, host
+}
+
+// pushTestImage copies the two-platform image of a test layout to a repository of the registry under each tag
+func pushTestImage(t *testing.T, client *Client, layout testLayout, repository string, tags ...string) {
+	t.Helper()
+	src, err := ref.New("ocidir://" + layout.dir + ":1.0")
+	if err != nil {
+		t.Fatalf("Failed to create layout reference: %v", err)
+	}
+	for _, tag := range tags {
+		dst, err := ref.New(repository + ":" + tag)
+		if err != nil {
+			t.Fatalf("Failed to create image reference: %v", err)
+		}
+		if err := client.client.ImageCopy(context.Background(), src, dst); err != nil {
+			t.Fatalf("Failed to push %s: %v", dst.CommonName(), err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Strategies for ordering the tags matched by a pattern
+const (
+	SortSemver        = "semver"         // Semantic versions (e.g., 1.27.3)
+	SortCalver        = "calver"         // Calendar versions, compared number by number (e.g., 2024.10.3)
+	SortNumeric       = "numeric"        // Whole numbers such as build numbers (e.g., 1234)
+	SortLexical       = "lexical"        // Plain string order, for date stamps (e.g., 20241003)
+	SortNewestCreated = "newest-created" // Creation time of the image each tag points to
+)
+
+// SortStrategies lists every supported sort strategy
+var SortStrategies = []string{SortSemver, SortCalver, SortNumeric, SortLexical, SortNewestCreated}
+
+// Candidate is a tag matched by a pattern
+type Candidate struct {
+	Tag     string // Tag as published
+	Version string // Part of the tag that is compared: the first capture group of the pattern, or the whole tag
+}
+
+// Match returns the tags matching a regular expression, in their original order.
+// The pattern is anchored, so "[0-9.]+" does not match "1.2-alpine".
+// If the pattern has a capture group, only the captured text is compared when sorting,
+// so `^(\d+\.\d+)-alpine$` compares "16.4-alpine" as "16.4".
+func Match(tags []string, pattern string) ([]Candidate, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag regex %q: %w", pattern, err)
+	}
+
+	var candidates []Candidate
+	for _, tag := range tags {
+		match := re.FindStringSubmatch(tag)
+		if match == nil {
+			continue
+		}
+		version := tag
+		if len(match) > 1 {
+			version = match[1]
+		}
+		candidates = append(candidates, Candidate{Tag: tag, Version: version})
+	}
+	return candidates, nil
+}
+
+// Matches reports whether a tag is non-empty and matches a pattern in full
+func Matches(tag, pattern string) bool {
+	if tag == "" {
+		return false
+	}
+	candidates, err := Match([]string{tag}, pattern)
+	return err == nil && len(candidates) == 1
+}
+
+// Sort orders candidates newest first using a strategy other than newest-created.
+// Candidates that are not valid versions for the strategy are returned separately.
+// When two candidates compare equal, the longer (more specific) tag comes first.
+func Sort(candidates []Candidate, strategy string) (sorted, skipped []Candidate, err error) {
+	var compare func(a, b string) int
+	var valid func(version string) bool
+
+	switch strategy {
+	case SortSemver:
+		versions := map[string]*semver.Version{}
+		valid = func(version string) bool {
+			v, err := semver.NewVersion(version)
+			versions[version] = v
+			return err == nil
+		}
+		compare = func(a, b string) int { return versions[a].Compare(versions[b]) }
+	case SortCalver:
+		valid = func(version string) bool { return len(numberPattern.FindAllString(version, -1)) > 0 }
+		compare = compareCalver
+	case SortNumeric:
+		valid = func(version string) bool {
+			_, err := strconv.ParseUint(version, 10, 64)
+			return err == nil
+		}
+		compare = compareNumeric
+	case SortLexical:
+		valid = func(string) bool { return true }
+		compare = strings.Compare
+	default:
+		return nil, nil, fmt.Errorf("invalid tag sort %q: must be one of %s", strategy, strings.Join(SortStrategies, ", "))
+	}
+
+	for _, candidate := range candidates {
+		if valid(candidate.Version) {
+			sorted = append(sorted, candidate)
+		} else {
+			skipped = append(skipped, candidate)
+		}
+	}
+
+	slices.SortStableFunc(sorted, func(a, b Candidate) int {
+		if c := compare(b.Version, a.Version); c != 0 {
+			return c
+		}
+		return len(b.Tag) - len(a.Tag)
+	})
+	return sorted, skipped, nil
+}
+
+// SortByCreated orders candidates by the creation time of their images, newest first.
+// Candidates without a creation time are returned separately.
+func SortByCreated(candidates []Candidate, created map[string]time.Time) (sorted, skipped []Candidate) {
+	for _, candidate := range candidates {
+		if created[candidate.Tag].IsZero() {
+			skipped = append(skipped, candidate)
+		} else {
+			sorted = append(sorted, candidate)
+		}
+	}
+
+	slices.SortStableFunc(sorted, func(a, b Candidate) int {
+		return created[b.Tag].Compare(created[a.Tag])
+	})
+	return sorted, skipped
+}
+
+// BySpecificity orders candidates with the most specific version first: the one made
+// of the most numbers (1.27.3 before 1.27), then the newest, then the longest tag
+func BySpecificity(candidates []Candidate) []Candidate {
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, func(a, b Candidate) int {
+		aNumbers := len(numberPattern.FindAllString(a.Version, -1))
+		bNumbers := len(numberPattern.FindAllString(b.Version, -1))
+		if aNumbers != bNumbers {
+			return bNumbers - aNumbers
+		}
+		if c := compareCalver(b.Version, a.Version); c != 0 {
+			return c
+		}
+		return len(b.Tag) - len(a.Tag)
+	})
+	return sorted
+}
+
+// numberPattern matches the numbers in a calendar version
+var numberPattern = regexp.MustCompile(`\d+`)
+
+// compareCalver compares calendar versions number by number, so 2024.10.3 is newer than 2024.9.12
+func compareCalver(a, b string) int {
+	aNumbers := numberPattern.FindAllString(a, -1)
+	bNumbers := numberPattern.FindAllString(b, -1)
+	for i := 0; i < len(aNumbers) && i < len(bNumbers); i++ {
+		if c := compareNumeric(aNumbers[i], bNumbers[i]); c != 0 {
+			return c
+		}
+	}
+	return len(aNumbers) - len(bNumbers)
+}
+
+// compareNumeric compares two strings of digits by their value
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// Describe lists candidates for messages, abbreviating long lists
+func Describe(candidates []Candidate) string {
+	const shown = 10
+
+	tags := make([]string, 0, shown)
+	for i, candidate := range candidates {
+		if i == shown {
+			break
+		}
+		tags = append(tags, candidate.Tag)
+	}
+
+	s := strings.Join(tags, ", ")
+	if len(candidates) > shown {
+		s += fmt.Sprintf(" and %d more", len(candidates)-shown)
+	}
+	return s
+}
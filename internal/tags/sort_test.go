@@ -0,0 +1,112 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// candidateTags returns the tags of a list of candidates
+func candidateTags(candidates []Candidate) []string {
+	tags := []string{}
+	for _, candidate := range candidates {
+		tags = append(tags, candidate.Tag)
+	}
+	return tags
+}
+
+func TestMatch(t *testing.T) {
+	candidates, err := Match([]string{"16.4-alpine", "16.4", "latest", "16.4-alpine3.20"}, `(\d+\.\d+)-alpine`)
+	if err != nil {
+		t.Fatalf("Match returned an error: %v", err)
+	}
+
+	expected := []Candidate{{Tag: "16.4-alpine", Version: "16.4"}}
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Expected %v, got %v", expected, candidates)
+	}
+}
+
+func TestMatchInvalidRegex(t *testing.T) {
+	if _, err := Match([]string{"latest"}, "("); err == nil {
+		t.Error("Expected an error for an invalid regex")
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		strategy string
+		tags     []string
+		expected []string
+		skipped  []string
+	}{
+		{SortSemver, []string{"1.9.0", "1.10.0", "1.10", "nightly"}, []string{"1.10.0", "1.10", "1.9.0"}, []string{"nightly"}},
+		{SortCalver, []string{"2024.9.12", "2024.10.3", "2023.12.1", "stable"}, []string{"2024.10.3", "2024.9.12", "2023.12.1"}, []string{"stable"}},
+		{SortNumeric, []string{"99", "1234", "0100", "b12"}, []string{"1234", "0100", "99"}, []string{"b12"}},
+		{SortLexical, []string{"20240901", "20241003", "20231231"}, []string{"20241003", "20240901", "20231231"}, []string{}},
+	}
+
+	for _, test := range tests {
+		candidates, _ := Match(test.tags, ".*")
+		sorted, skipped, err := Sort(candidates, test.strategy)
+		if err != nil {
+			t.Errorf("Sort by %s returned an error: %v", test.strategy, err)
+			continue
+		}
+		if got := candidateTags(sorted); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected %v sorted by %s, got %v", test.expected, test.strategy, got)
+		}
+		if got := candidateTags(skipped); !reflect.DeepEqual(got, test.skipped) {
+			t.Errorf("Expected %v skipped by %s, got %v", test.skipped, test.strategy, got)
+		}
+	}
+}
+
+func TestSortInvalidStrategy(t *testing.T) {
+	if _, _, err := Sort(nil, "newest"); err == nil {
+		t.Error("Expected an error for an invalid sort strategy")
+	}
+}
+
+func TestSortByCreated(t *testing.T) {
+	candidates, _ := Match([]string{"a", "b", "c"}, ".*")
+	now := time.Now()
+	created := map[string]time.Time{
+		"a": now.Add(-2 * time.Hour),
+		"b": now,
+	}
+
+	sorted, skipped := SortByCreated(candidates, created)
+	if got := candidateTags(sorted); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("Expected [b a], got %v", got)
+	}
+	if got := candidateTags(skipped); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("Expected [c] skipped, got %v", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	candidates, _ := Match([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}, ".*")
+	expected := "1, 2, 3, 4, 5, 6, 7, 8, 9, 10 and 2 more"
+	if got := Describe(candidates); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestBySpecificity(t *testing.T) {
+	candidates, _ := Match([]string{"1", "1.27", "1.26.9", "1.27.3", "1.27.3-alpine"}, `(\d+(?:\.\d+)*)(?:-alpine)?`)
+
+	expected := []string{"1.27.3-alpine", "1.27.3", "1.26.9", "1.27", "1"}
+	if got := candidateTags(BySpecificity(candidates)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	if !Matches("1.27.3", `\d+\.\d+\.\d+`) {
+		t.Error("Expected 1.27.3 to match")
+	}
+	if Matches("1.27", `\d+\.\d+\.\d+`) || Matches("", `.*`) {
+		t.Error("Expected 1.27 and an empty tag not to match")
+	}
+}
@@ -0,0 +1,53 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ErrNoMatchingTag is returned when no tag satisfies the selection rules
+var ErrNoMatchingTag = errors.New("no matching tag")
+
+// Semver returns the highest tag satisfying a semver constraint (e.g., ">=16 <17").
+// If suffix is set, only tags ending in it are considered, and the suffix is removed
+// before the version is parsed, so "16.4-alpine" with suffix "-alpine" is version 16.4.
+func Semver(tags []string, constraint, suffix string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag constraint %q: %w", constraint, err)
+	}
+
+	var best string
+	var bestVersion *semver.Version
+
+	for _, tag := range tags {
+		versionText, hasSuffix := strings.CutSuffix(tag, suffix)
+		if !hasSuffix || versionText == "" {
+			continue
+		}
+
+		version, err := semver.NewVersion(versionText)
+		if err != nil || !c.Check(version) {
+			continue
+		}
+
+		// Prefer the more specific tag when two tags parse to the same version (e.g., 16.4 and 16.4.0)
+		if bestVersion == nil || version.GreaterThan(bestVersion) ||
+			(version.Equal(bestVersion) && len(tag) > len(best)) {
+			best = tag
+			bestVersion = version
+		}
+	}
+
+	if best == "" {
+		if suffix != "" {
+			return "", fmt.Errorf("%w: no tag ending in %q satisfies %q", ErrNoMatchingTag, suffix, constraint)
+		}
+		return "", fmt.Errorf("%w: no tag satisfies %q", ErrNoMatchingTag, constraint)
+	}
+
+	return best, nil
+}
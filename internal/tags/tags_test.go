@@ -0,0 +1,49 @@
+package tags
+
+import (
+	"errors"
+	"testing"
+)
+
+// postgresTags is a sample of the tags published for library/postgres
+var postgresTags = []string{
+	"latest", "alpine", "15", "15.8", "15.8-alpine", "16", "16-alpine",
+	"16.3", "16.3-alpine", "16.4", "16.4-alpine", "16.4-alpine3.20", "17rc1-alpine", "17.0", "17.0-alpine",
+}
+
+func TestSemver(t *testing.T) {
+	tests := []struct {
+		constraint string
+		suffix     string
+		expected   string
+	}{
+		{">=16 <17", "-alpine", "16.4-alpine"},
+		{">=16 <17", "", "16.4"},
+		{"~15", "-alpine", "15.8-alpine"},
+		{"*", "-alpine", "17.0-alpine"},
+	}
+
+	for _, test := range tests {
+		tag, err := Semver(postgresTags, test.constraint, test.suffix)
+		if err != nil {
+			t.Errorf("Unexpected error for %q %q: %v", test.constraint, test.suffix, err)
+			continue
+		}
+		if tag != test.expected {
+			t.Errorf("Expected %s for %q %q, got %s", test.expected, test.constraint, test.suffix, tag)
+		}
+	}
+}
+
+func TestSemverNoMatch(t *testing.T) {
+	_, err := Semver(postgresTags, ">=18", "-alpine")
+	if !errors.Is(err, ErrNoMatchingTag) {
+		t.Errorf("Expected ErrNoMatchingTag, got %v", err)
+	}
+}
+
+func TestSemverInvalidConstraint(t *testing.T) {
+	if _, err := Semver(postgresTags, "not a constraint", ""); err == nil {
+		t.Error("Expected an error for an invalid constraint")
+	}
+}
@@ -0,0 +1,391 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+// ErrNoValidSignature is returned when none of an image's signatures satisfies the policy
+var ErrNoValidSignature = errors.New("no valid signature")
+
+// signatureType is the type cosign writes into the payload of container image signatures
+const signatureType = "cosign container image signature"
+
+// Fulcio certificate extensions holding the OIDC issuer of the signer's identity
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1} // Raw string, deprecated
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8} // DER-encoded UTF8String
+)
+
+// Signature is a cosign signature of an image, as stored in a layer of its signature manifest
+type Signature struct {
+	Payload     []byte // Simple signing payload naming the signed digest
+	Signature   string // Base64 signature of the payload
+	Certificate string // PEM certificate of the signer, for keyless signatures
+	Chain       string // PEM intermediate certificates of the signer's certificate
+	Bundle      string // JSON transparency log bundle recording when the payload was signed
+}
+
+// Verifier checks signatures against a container's verify settings
+type Verifier struct {
+	key           crypto.PublicKey // Public key signatures must be made with, nil for keyless
+	roots         *x509.CertPool   // Certificate authorities keyless certificates must chain to
+	intermediates *x509.CertPool   // Intermediate certificates from the roots file
+	identity      string           // Exact identity (email or URI) of the keyless signer
+	identityRegex *regexp.Regexp   // Pattern the identity of the keyless signer must match
+	issuer        string           // OIDC issuer the keyless signer authenticated with
+	rekorKey      crypto.PublicKey // Public key of the transparency log that must have recorded signatures
+	rekorLogID    string           // ID of that transparency log, the hex SHA-256 of its public key
+}
+
+// New reads the keys and certificates a container's verify settings refer to
+func New(config models.VerifyConfig) (*Verifier, error) {
+	v := &Verifier{identity: config.Identity, issuer: config.Issuer}
+
+	var err error
+	v.rekorKey, err = readPublicKey(config.RekorKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(v.rekorKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key %s: %w", config.RekorKey, err)
+	}
+	logID := sha256.Sum256(der)
+	v.rekorLogID = hex.EncodeToString(logID[:])
+
+	if config.Key != "" {
+		v.key, err = readPublicKey(config.Key)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	if config.IdentityRegex != "" {
+		re, err := regexp.Compile("^(?:" + config.IdentityRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity_regex %q: %w", config.IdentityRegex, err)
+		}
+		v.identityRegex = re
+	}
+
+	certs, err := readCertificates(config.Roots)
+	if err != nil {
+		return nil, err
+	}
+	v.roots = x509.NewCertPool()
+	v.intermediates = x509.NewCertPool()
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			v.roots.AddCert(cert)
+		} else {
+			v.intermediates.AddCert(cert)
+		}
+	}
+	return v, nil
+}
+
+// Verify checks that a signature was made over the given digest by the configured key or identity
+// and recorded in the transparency log. Only the log's signature of the entry (its signed entry
+// timestamp) is checked, offline: inclusion proofs and the certificate's SCTs are not.
+func (v *Verifier) Verify(digest string, sig Signature) error {
+	signature, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	key := v.key
+	if key == nil {
+		key, err = v.verifyCertificate(sig, signature)
+		if err != nil {
+			return err
+		}
+	} else if _, err := v.verifyBundle(sig, signature); err != nil {
+		return err
+	}
+	if err := verifySignature(key, sig.Payload, signature); err != nil {
+		return err
+	}
+
+	return checkPayload(sig.Payload, digest)
+}
+
+// Describe names the key or identity signatures are verified against, for messages
+func (v *Verifier) Describe() string {
+	switch {
+	case v.key != nil:
+		return "public key"
+	case v.identityRegex != nil:
+		return fmt.Sprintf("identity matching %q from %s", v.identityRegex.String(), v.issuer)
+	}
+	return fmt.Sprintf("identity %s from %s", v.identity, v.issuer)
+}
+
+// verifyCertificate checks a keyless signature's certificate: it must have been valid when the
+// transparency log recorded the signature, chain to the configured roots and name the expected
+// signer. It returns the certificate's public key.
+func (v *Verifier) verifyCertificate(sig Signature, signature []byte) (crypto.PublicKey, error) {
+	if sig.Certificate == "" {
+		return nil, fmt.Errorf("signature has no certificate")
+	}
+	certs, err := parseCertificates([]byte(sig.Certificate + "\n" + sig.Chain))
+	if err != nil {
+		return nil, err
+	}
+	cert := certs[0]
+
+	signed, err := v.verifyBundle(sig, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := v.intermediates.Clone()
+	for _, chainCert := range certs[1:] {
+		intermediates.AddCert(chainCert)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signed,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("untrusted certificate: %w", err)
+	}
+
+	if err := v.checkIdentity(cert); err != nil {
+		return nil, err
+	}
+	return cert.PublicKey, nil
+}
+
+// checkIdentity checks the signer's identity and issuer recorded in a certificate
+func (v *Verifier) checkIdentity(cert *x509.Certificate) error {
+	issuer, err := certificateIssuer(cert)
+	if err != nil {
+		return err
+	}
+	if issuer != v.issuer {
+		return fmt.Errorf("certificate issuer %q is not %q", issuer, v.issuer)
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	for _, identity := range identities {
+		if identity == v.identity || (v.identityRegex != nil && v.identityRegex.MatchString(identity)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate identities %q don't match the expected identity", identities)
+}
+
+// rekorBundle is the transparency log entry cosign stores with a signature
+type rekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+// rekorPayload is the part of a transparency log entry signed by the log.
+// Its fields are in the order of their canonical JSON encoding.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// hashedRekord is the transparency log body recording a signature over a hash
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content []byte `json:"content"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// verifyBundle checks that the transparency log recorded this signature over this payload,
+// and returns when it did so, which is when the certificate has to have been valid
+func (v *Verifier) verifyBundle(sig Signature, signature []byte) (time.Time, error) {
+	if sig.Bundle == "" {
+		return time.Time{}, fmt.Errorf("signature has no transparency log bundle")
+	}
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(sig.Bundle), &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode transparency log bundle: %w", err)
+	}
+	if bundle.Payload.LogID != v.rekorLogID {
+		return time.Time{}, fmt.Errorf("transparency log bundle is from log %s, not the one of rekor_key", bundle.Payload.LogID)
+	}
+
+	// The log signs the canonical JSON of the entry: sorted keys, no whitespace, no HTML escaping
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(bundle.Payload); err != nil {
+		return time.Time{}, fmt.Errorf("failed to encode transparency log entry: %w", err)
+	}
+	if err := verifySignature(v.rekorKey, bytes.TrimSuffix(payload.Bytes(), []byte("\n")), bundle.SignedEntryTimestamp); err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log timestamp: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode transparency log entry: %w", err)
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode transparency log entry: %w", err)
+	}
+	hash := sha256.Sum256(sig.Payload)
+	if entry.Kind != "hashedrekord" || entry.Spec.Data.Hash.Algorithm != "sha256" ||
+		entry.Spec.Data.Hash.Value != hex.EncodeToString(hash[:]) || !bytes.Equal(entry.Spec.Signature.Content, signature) {
+		return time.Time{}, fmt.Errorf("transparency log entry is for another signature")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// simpleSigning is the payload cosign signs, naming the digest of the signed image
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// checkPayload checks that a verified payload is a cosign signature of the given digest
+func checkPayload(payload []byte, digest string) error {
+	var signed simpleSigning
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return fmt.Errorf("failed to decode signature payload: %w", err)
+	}
+	if signed.Critical.Type != signatureType {
+		return fmt.Errorf("unexpected signature type %q", signed.Critical.Type)
+	}
+	if signed.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s", signed.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// verifySignature checks a signature of data made with the private half of key, hashed with SHA-256 like cosign does
+func verifySignature(key crypto.PublicKey, data, signature []byte) error {
+	hash := sha256.Sum256(data)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("signature does not match")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// certificateIssuer returns the OIDC issuer Fulcio recorded in a certificate
+func certificateIssuer(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &issuer, "utf8"); err != nil {
+				return "", fmt.Errorf("failed to decode certificate issuer: %w", err)
+			}
+			return issuer, nil
+		case ext.Id.Equal(oidIssuer):
+			return string(ext.Value), nil
+		}
+	}
+	return "", fmt.Errorf("certificate has no issuer")
+}
+
+// readPublicKey reads a PEM public key, such as cosign.pub
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM public key", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// readCertificates reads a PEM file with one or more certificates
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates %s: %w", path, err)
+	}
+	return certs, nil
+}
+
+// parseCertificates parses every certificate in PEM data, in order
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,320 @@
+package verify
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdrake/container-digest/internal/models"
+)
+
+const (
+	testDigest   = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testIdentity = "https://github.com/example/app/.github/workflows/release.yml@refs/tags/v1.0.0"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// testPayload returns the payload cosign signs for a digest
+func testPayload(digest string) []byte {
+	return []byte(`{"critical":{"identity":{"docker-reference":"ghcr.io/example/app"},"image":{"docker-manifest-digest":"` +
+		digest + `"},"type":"cosign container image signature"},"optional":null}`)
+}
+
+// newTestKey generates a P-256 key like the ones cosign creates
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+// sign signs the SHA-256 of data like cosign does
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return signature
+}
+
+// writePublicKey writes a PEM public key file and returns its path
+func writePublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	return path
+}
+
+// signWithKey creates a signature of payload made with key and recorded in the log of rekorKey
+func signWithKey(t *testing.T, key, rekorKey *ecdsa.PrivateKey, payload []byte) Signature {
+	t.Helper()
+	signature := sign(t, key, payload)
+	return Signature{
+		Payload:   payload,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		Bundle:    testBundle(t, rekorKey, payload, signature, []byte("public key"), time.Now()),
+	}
+}
+
+func TestVerifyWithKey(t *testing.T) {
+	key, rekorKey := newTestKey(t), newTestKey(t)
+	verifier, err := New(models.VerifyConfig{Key: writePublicKey(t, key), RekorKey: writePublicKey(t, rekorKey)})
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	payload := testPayload(testDigest)
+	signature := signWithKey(t, key, rekorKey, payload)
+	if err := verifier.Verify(testDigest, signature); err != nil {
+		t.Errorf("Expected the signature to be valid, got %v", err)
+	}
+
+	// A valid signature of another digest doesn't count
+	other := "sha256:" + strings.Repeat("f", 64)
+	if err := verifier.Verify(other, signature); err == nil {
+		t.Error("Expected a signature of another digest to be rejected")
+	}
+
+	// Nor does a signature made with another key
+	forged := signWithKey(t, newTestKey(t), rekorKey, payload)
+	if err := verifier.Verify(testDigest, forged); err == nil {
+		t.Error("Expected a signature made with another key to be rejected")
+	}
+}
+
+func TestVerifyWithKeyBundle(t *testing.T) {
+	key, rekorKey := newTestKey(t), newTestKey(t)
+	verifier, err := New(models.VerifyConfig{Key: writePublicKey(t, key), RekorKey: writePublicKey(t, rekorKey)})
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	payload := testPayload(testDigest)
+
+	// A signature the log never recorded is rejected even when the key matches
+	signature := signWithKey(t, key, rekorKey, payload)
+	signature.Bundle = ""
+	if err := verifier.Verify(testDigest, signature); err == nil {
+		t.Error("Expected a signature without a bundle to be rejected")
+	}
+
+	// So is one recorded in another log
+	if err := verifier.Verify(testDigest, signWithKey(t, key, newTestKey(t), payload)); err == nil {
+		t.Error("Expected a bundle from another log to be rejected")
+	}
+
+	// Or a bundle recording another signature
+	signature = signWithKey(t, key, rekorKey, payload)
+	signature.Bundle = signWithKey(t, key, rekorKey, payload).Bundle
+	if err := verifier.Verify(testDigest, signature); err == nil {
+		t.Error("Expected a bundle for another signature to be rejected")
+	}
+}
+
+// keylessFixture is a certificate authority and transparency log for keyless signatures
+type keylessFixture struct {
+	root     *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	config   models.VerifyConfig
+}
+
+// newKeylessFixture creates a root certificate and Rekor key, and a config trusting them
+func newKeylessFixture(t *testing.T) *keylessFixture {
+	t.Helper()
+	f := &keylessFixture{rootKey: newTestKey(t), rekorKey: newTestKey(t)}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio root"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &f.rootKey.PublicKey, f.rootKey)
+	if err != nil {
+		t.Fatalf("Failed to create root certificate: %v", err)
+	}
+	f.root, _ = x509.ParseCertificate(der)
+
+	roots := filepath.Join(t.TempDir(), "fulcio.pem")
+	if err := os.WriteFile(roots, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write roots: %v", err)
+	}
+
+	f.config = models.VerifyConfig{Identity: testIdentity, Issuer: testIssuer, Roots: roots, RekorKey: writePublicKey(t, f.rekorKey)}
+	return f
+}
+
+// sign creates a keyless signature of payload by identity, with a certificate valid for
+// ten minutes from signed and a transparency log bundle recording it at that time
+func (f *keylessFixture) sign(t *testing.T, payload []byte, identity, issuer string, signed time.Time) Signature {
+	t.Helper()
+	key := newTestKey(t)
+
+	issuerValue, err := asn1.MarshalWithParams(issuer, "utf8")
+	if err != nil {
+		t.Fatalf("Failed to encode issuer: %v", err)
+	}
+	uri, _ := url.Parse(identity)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signed.Add(-time.Minute),
+		NotAfter:        signed.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, f.root, &key.PublicKey, f.rootKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	signature := sign(t, key, payload)
+	return Signature{
+		Payload:     payload,
+		Signature:   base64.StdEncoding.EncodeToString(signature),
+		Certificate: string(certificate),
+		Bundle:      testBundle(t, f.rekorKey, payload, signature, certificate, signed),
+	}
+}
+
+// testBundle creates the transparency log bundle cosign stores with a signature,
+// recording it in the log of rekorKey at the given time
+func testBundle(t *testing.T, rekorKey *ecdsa.PrivateKey, payload, signature, publicKey []byte, signed time.Time) string {
+	t.Helper()
+	hash := sha256.Sum256(payload)
+	body, _ := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data":      map[string]any{"hash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(hash[:])}},
+			"signature": map[string]any{"content": signature, "publicKey": map[string]any{"content": publicKey}},
+		},
+	})
+	der, _ := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	logID := sha256.Sum256(der)
+
+	// Rekor signs the canonical JSON of the entry, which for these fields is what
+	// encoding/json produces for a map: sorted keys and no whitespace
+	entry := map[string]any{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": signed.Unix(),
+		"logID":          hex.EncodeToString(logID[:]),
+		"logIndex":       12345,
+	}
+	canonical, _ := json.Marshal(entry)
+	bundle, _ := json.Marshal(map[string]any{
+		"SignedEntryTimestamp": sign(t, rekorKey, canonical),
+		"Payload":              entry,
+	})
+	return string(bundle)
+}
+
+func TestVerifyKeyless(t *testing.T) {
+	f := newKeylessFixture(t)
+	// The certificate expired long ago, but was valid when the log recorded the signature
+	signed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	payload := testPayload(testDigest)
+
+	verifier, err := New(f.config)
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	if err := verifier.Verify(testDigest, f.sign(t, payload, testIdentity, testIssuer, signed)); err != nil {
+		t.Errorf("Expected the keyless signature to be valid, got %v", err)
+	}
+
+	regexConfig := f.config
+	regexConfig.Identity = ""
+	regexConfig.IdentityRegex = `https://github\.com/example/app/\.github/workflows/.*`
+	regexVerifier, err := New(regexConfig)
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	if err := regexVerifier.Verify(testDigest, f.sign(t, payload, testIdentity, testIssuer, signed)); err != nil {
+		t.Errorf("Expected the identity to match the regex, got %v", err)
+	}
+
+	tests := map[string]Signature{
+		"another identity": f.sign(t, payload, "https://github.com/attacker/app/.github/workflows/release.yml@refs/heads/main", testIssuer, signed),
+		"another issuer":   f.sign(t, payload, testIdentity, "https://accounts.google.com", signed),
+		"another root":     newKeylessFixture(t).sign(t, payload, testIdentity, testIssuer, signed),
+	}
+	for name, signature := range tests {
+		if err := verifier.Verify(testDigest, signature); err == nil {
+			t.Errorf("Expected a signature by %s to be rejected", name)
+		}
+	}
+}
+
+func TestVerifyKeylessBundle(t *testing.T) {
+	f := newKeylessFixture(t)
+	signed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier, err := New(f.config)
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	// Without a bundle there is no trusted time to check the certificate at
+	signature := f.sign(t, testPayload(testDigest), testIdentity, testIssuer, signed)
+	signature.Bundle = ""
+	if err := verifier.Verify(testDigest, signature); err == nil {
+		t.Error("Expected a keyless signature without a bundle to be rejected")
+	}
+
+	// A bundle recording another signature doesn't vouch for this one
+	signature = f.sign(t, testPayload(testDigest), testIdentity, testIssuer, signed)
+	signature.Bundle = f.sign(t, testPayload(testDigest), testIdentity, testIssuer, signed).Bundle
+	if err := verifier.Verify(testDigest, signature); err == nil {
+		t.Error("Expected a bundle for another signature to be rejected")
+	}
+
+	// Moving the recorded time invalidates the log's signature
+	signature = f.sign(t, testPayload(testDigest), testIdentity, testIssuer, signed)
+	signature.Bundle = strings.Replace(signature.Bundle, `"logIndex":12345`, `"logIndex":12346`, 1)
+	if err := verifier.Verify(testDigest, signature); err == nil {
+		t.Error("Expected a tampered bundle to be rejected")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	rekorKey := writePublicKey(t, newTestKey(t))
+	if _, err := New(models.VerifyConfig{Key: filepath.Join(t.TempDir(), "missing.pub"), RekorKey: rekorKey}); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+
+	_, err := New(models.VerifyConfig{Identity: testIdentity, Issuer: testIssuer, Roots: filepath.Join(t.TempDir(), "missing.pem"), RekorKey: rekorKey})
+	if err == nil || errors.Is(err, ErrNoValidSignature) {
+		t.Errorf("Expected an error reading the roots, got %v", err)
+	}
+}